@@ -0,0 +1,252 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"text/template"
+)
+
+const (
+	// frameworkModulePath is the module path of the framework, which ff is
+	// part of.
+	frameworkModulePath = "github.com/GoogleCloudPlatform/functions-framework-go"
+	// wrapperModulePath is the module path of the generated wrapper module.
+	wrapperModulePath = "functions-framework-go/ff/wrapper"
+	// localVersion is the version the wrapper module requires the function's
+	// module at, which is replaced by its local directory.
+	localVersion = "v0.0.0-00010101000000-000000000000"
+	// listenerFDEnv is set, in watch mode, to the file descriptor of the
+	// listener inherited by the server from ff.
+	listenerFDEnv = "FF_LISTENER_FD"
+	// readyFDEnv is set, in watch mode, to the file descriptor the server
+	// writes to once it is ready to serve.
+	readyFDEnv = "FF_READY_FD"
+)
+
+// frameworkVersion is the version of the framework ff is built from. The
+// wrapper module requires at least this version, as its main package uses the
+// API of the framework ff is built from. It is empty if ff is not built from a
+// version that can be required, as when built in a clone of the framework, or
+// if ff is built in the function's module, where it already uses the same
+// framework as the wrapper module.
+var frameworkVersion = builtFrameworkVersion()
+
+func builtFrameworkVersion() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok || bi.Main.Path != frameworkModulePath {
+		return ""
+	}
+	// Versions of modified clones, such as "v1.9.1-0.20260101000000-abcdef123456+dirty",
+	// cannot be downloaded.
+	if v := bi.Main.Version; strings.HasPrefix(v, "v") && !strings.Contains(v, "+") {
+		return v
+	}
+	return ""
+}
+
+// wrapperMain is the main package of the wrapper module, which serves the
+// functions registered by the function package.
+var wrapperMain = template.Must(template.New("main.go").Parse(`// Code generated by ff. DO NOT EDIT.
+
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
+	_ "{{.ImportPath}}"
+)
+
+func main() {
+	cfg, err := funcframework.LoadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	var opts []funcframework.ServerOption
+	if s := os.Getenv("{{.ListenerFDEnv}}"); s != "" {
+		fd, err := strconv.Atoi(s)
+		if err != nil {
+			log.Fatalf("Invalid {{.ListenerFDEnv}}: %v", err)
+		}
+		l, err := net.FileListener(os.NewFile(uintptr(fd), "listener"))
+		if err != nil {
+			log.Fatalf("Failed to use the inherited listener: %v", err)
+		}
+		opts = append(opts, funcframework.WithListener(l))
+	}
+	if s := os.Getenv("{{.ReadyFDEnv}}"); s != "" {
+		fd, err := strconv.Atoi(s)
+		if err != nil {
+			log.Fatalf("Invalid {{.ReadyFDEnv}}: %v", err)
+		}
+		ready := os.NewFile(uintptr(fd), "ready")
+		// Added after the start hooks of the function package, so that it
+		// runs once they succeeded, right before the server serves.
+		funcframework.OnStart(func(context.Context) error {
+			defer ready.Close()
+			_, err := ready.Write([]byte{1})
+			return err
+		})
+	}
+	if err := funcframework.StartWithConfig(cfg, opts...); err != nil {
+		log.Fatalf("Failed to start functions framework: %v", err)
+	}
+}
+`))
+
+// functionPackage describes the package containing the function to run.
+type functionPackage struct {
+	// ImportPath is the import path of the package.
+	ImportPath string
+	// Module is the module containing the package.
+	Module struct {
+		Path  string
+		Dir   string
+		GoMod string
+	}
+}
+
+// goMod is the part of the output of `go mod edit -json` used to generate the
+// go.mod of the wrapper module.
+type goMod struct {
+	Replace []struct {
+		Old modVersion
+		New modVersion
+	}
+}
+
+type modVersion struct {
+	Path    string
+	Version string
+}
+
+// loadFunctionPackage returns the package in the source directory.
+func loadFunctionPackage(source string) (*functionPackage, error) {
+	out, err := goCommand(source, "list", "-json", ".").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the function package in %s: %v", source, commandError(err))
+	}
+	var pkg functionPackage
+	if err := json.Unmarshal(out, &pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse go list output: %v", err)
+	}
+	if pkg.Module.Path == "" || pkg.Module.GoMod == "" {
+		return nil, fmt.Errorf("the function package in %s is not part of a module: add a go.mod file with `go mod init`", source)
+	}
+	return &pkg, nil
+}
+
+// writeWrapperModule writes a module serving the functions of pkg to dir. The
+// go.mod of the wrapper module is a copy of the go.mod of the function's
+// module, so that it is built with the same dependencies, replacing the
+// function's module with its local directory and requiring at least
+// frameworkVersion of the framework.
+func writeWrapperModule(dir string, pkg *functionPackage) error {
+	modFile := filepath.Join(dir, "go.mod")
+	if err := copyFile(pkg.Module.GoMod, modFile); err != nil {
+		return err
+	}
+	if err := copyFile(filepath.Join(pkg.Module.Dir, "go.sum"), filepath.Join(dir, "go.sum")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	out, err := goCommand(dir, "mod", "edit", "-json").Output()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", pkg.Module.GoMod, commandError(err))
+	}
+	var mod goMod
+	if err := json.Unmarshal(out, &mod); err != nil {
+		return fmt.Errorf("failed to parse go mod edit output: %v", err)
+	}
+	edits := []string{
+		"-module=" + wrapperModulePath,
+		"-require=" + pkg.Module.Path + "@" + localVersion,
+		"-replace=" + pkg.Module.Path + "=" + pkg.Module.Dir,
+	}
+	// This does not downgrade the framework: the function's module still
+	// requires its own version of it, and the highest of both is selected.
+	if frameworkVersion != "" {
+		edits = append(edits, "-require="+frameworkModulePath+"@"+frameworkVersion)
+	}
+	// Replacements by relative paths are relative to the function's module.
+	for _, r := range mod.Replace {
+		if r.New.Version != "" || filepath.IsAbs(r.New.Path) {
+			continue
+		}
+		old := r.Old.Path
+		if r.Old.Version != "" {
+			old += "@" + r.Old.Version
+		}
+		edits = append(edits, "-replace="+old+"="+filepath.Join(pkg.Module.Dir, r.New.Path))
+	}
+	if out, err := goCommand(dir, append([]string{"mod", "edit"}, edits...)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to generate the wrapper go.mod: %v\n%s", err, out)
+	}
+
+	var main bytes.Buffer
+	data := struct{ ImportPath, ListenerFDEnv, ReadyFDEnv string }{pkg.ImportPath, listenerFDEnv, readyFDEnv}
+	if err := wrapperMain.Execute(&main, data); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "main.go"), main.Bytes(), 0644)
+}
+
+// buildWrapperModule builds the wrapper module in dir to the binary at out.
+// The build output, including any compilation error, is returned as the
+// error.
+func buildWrapperModule(dir, out string) error {
+	cmd := goCommand(dir, "build", "-mod=mod", "-o", out, ".")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("build failed: %v\n%s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// goCommand returns a go command running in dir. Workspaces are disabled, as
+// the wrapper module is built on its own.
+func goCommand(dir string, args ...string) *exec.Cmd {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off")
+	return cmd
+}
+
+// commandError adds the standard error of a failed command to err.
+func commandError(err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%v\n%s", err, bytes.TrimSpace(exitErr.Stderr))
+	}
+	return err
+}
+
+func copyFile(src, dst string) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, b, 0644)
+}
@@ -0,0 +1,206 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testFunction = `package userfn
+
+import (
+	"net/http"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+)
+
+func init() {
+	functions.HTTP("Hello", func(w http.ResponseWriter, r *http.Request) {})
+}
+`
+
+// writeTestModule writes a module containing a function package to a
+// temporary directory, depending on the framework in this repository through
+// a relative replacement, and returns the path of the package.
+func writeTestModule(t *testing.T) string {
+	t.Helper()
+	repo, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	rel, err := filepath.Rel(dir, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goMod := "module example.com/userfn\n\ngo 1.21\n\n" +
+		"require github.com/GoogleCloudPlatform/functions-framework-go v1.8.0\n\n" +
+		"replace github.com/GoogleCloudPlatform/functions-framework-go => " + rel + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0644); err != nil {
+		t.Fatal(err)
+	}
+	repoSum, err := os.ReadFile(filepath.Join(repo, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.sum"), repoSum, 0644); err != nil {
+		t.Fatal(err)
+	}
+	pkgDir := filepath.Join(dir, "fn")
+	if err := os.Mkdir(pkgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pkgDir, "fn.go"), []byte(testFunction), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return pkgDir
+}
+
+func TestWriteWrapperModule(t *testing.T) {
+	source := writeTestModule(t)
+	pkg, err := loadFunctionPackage(source)
+	if err != nil {
+		t.Fatalf("loadFunctionPackage(): %v", err)
+	}
+	if pkg.ImportPath != "example.com/userfn/fn" || pkg.Module.Path != "example.com/userfn" {
+		t.Errorf("loadFunctionPackage() = %q in module %q, want %q in module %q", pkg.ImportPath, pkg.Module.Path, "example.com/userfn/fn", "example.com/userfn")
+	}
+
+	dir := t.TempDir()
+	if err := writeWrapperModule(dir, pkg); err != nil {
+		t.Fatalf("writeWrapperModule(): %v", err)
+	}
+
+	out, err := goCommand(dir, "mod", "edit", "-json").Output()
+	if err != nil {
+		t.Fatalf("go mod edit -json: %v", commandError(err))
+	}
+	var mod struct {
+		Module  modVersion
+		Require []modVersion
+		Replace []struct {
+			Old modVersion
+			New modVersion
+		}
+	}
+	if err := json.Unmarshal(out, &mod); err != nil {
+		t.Fatal(err)
+	}
+	if mod.Module.Path != wrapperModulePath {
+		t.Errorf("wrapper module path = %q, want %q", mod.Module.Path, wrapperModulePath)
+	}
+	replaced := map[string]string{}
+	for _, r := range mod.Replace {
+		replaced[r.Old.Path] = r.New.Path
+	}
+	if got := replaced["example.com/userfn"]; got != pkg.Module.Dir {
+		t.Errorf("function module replaced by %q, want %q", got, pkg.Module.Dir)
+	}
+	if got := replaced["github.com/GoogleCloudPlatform/functions-framework-go"]; !filepath.IsAbs(got) {
+		t.Errorf("relative replacement not made absolute: %q", got)
+	}
+
+	main, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(main), `_ "example.com/userfn/fn"`) {
+		t.Errorf("main.go does not import the function package:\n%s", main)
+	}
+
+	bin := filepath.Join(t.TempDir(), "server")
+	if err := buildWrapperModule(dir, bin); err != nil {
+		t.Fatalf("buildWrapperModule(): %v", err)
+	}
+	if _, err := os.Stat(bin); err != nil {
+		t.Errorf("server binary not built: %v", err)
+	}
+}
+
+func TestWriteWrapperModuleRequiresFramework(t *testing.T) {
+	tcs := []struct {
+		name             string
+		frameworkVersion string
+		want             string
+	}{
+		{
+			name:             "ff built from a release",
+			frameworkVersion: "v1.99.0",
+			want:             "v1.99.0",
+		},
+		{
+			name: "ff built from a clone",
+			want: "v1.7.4",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func(v string) { frameworkVersion = v }(frameworkVersion)
+			frameworkVersion = tc.frameworkVersion
+
+			// The function's module requires a released framework without
+			// replacing it.
+			src := t.TempDir()
+			goMod := "module example.com/userfn\n\ngo 1.21\n\n" +
+				"require " + frameworkModulePath + " v1.7.4\n"
+			if err := os.WriteFile(filepath.Join(src, "go.mod"), []byte(goMod), 0644); err != nil {
+				t.Fatal(err)
+			}
+			pkg := &functionPackage{ImportPath: "example.com/userfn"}
+			pkg.Module.Path = "example.com/userfn"
+			pkg.Module.Dir = src
+			pkg.Module.GoMod = filepath.Join(src, "go.mod")
+
+			dir := t.TempDir()
+			if err := writeWrapperModule(dir, pkg); err != nil {
+				t.Fatalf("writeWrapperModule(): %v", err)
+			}
+			out, err := goCommand(dir, "mod", "edit", "-json").Output()
+			if err != nil {
+				t.Fatalf("go mod edit -json: %v", commandError(err))
+			}
+			var mod struct{ Require []modVersion }
+			if err := json.Unmarshal(out, &mod); err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			for _, r := range mod.Require {
+				if r.Path == frameworkModulePath {
+					got = r.Version
+				}
+			}
+			if got != tc.want {
+				t.Errorf("wrapper module requires the framework at %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildError(t *testing.T) {
+	source := writeTestModule(t)
+	if err := os.WriteFile(filepath.Join(source, "broken.go"), []byte("package userfn\n\nfunc broken() { undefined() }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := build(source, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "undefined: undefined") {
+		t.Errorf("build() error = %v, want the compilation error", err)
+	}
+}
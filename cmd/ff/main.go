@@ -0,0 +1,159 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command ff runs the functions of a package locally, without writing a main
+// package for them:
+//
+//	go run github.com/GoogleCloudPlatform/functions-framework-go/cmd/ff --source ./myfunc --target MyFn
+//
+// ff generates a main package serving the functions registered by the
+// package in the source directory, builds it in a temporary module using the
+// dependencies of the package's module and runs it. Functions must be
+// registered declaratively, with the functions package.
+//
+// The --target, --port, --signature-type and --debug flags, and the
+// corresponding environment variables, configure the server as described in
+// funcframework.LoadConfig.
+//
+// With --watch, ff rebuilds and restarts the server whenever the Go sources of
+// the package's module change, printing any build error and keeping the
+// previous server running until the sources build again.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("ff: ")
+
+	cfg, err := funcframework.LoadConfig(nil)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if cfg.Source == "" {
+		cfg.Source = "."
+	}
+	cfg.RegisterFlags(flag.CommandLine)
+	watchMode := flag.Bool("watch", false, "rebuild and restart the server when the sources change")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: ff [flags]\n\nRuns the functions registered by the package in the source directory.\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	if *watchMode {
+		if err := watch(cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	code, err := run(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(code)
+}
+
+// run builds and runs the functions configured by cfg, returning the exit
+// code of the server.
+func run(cfg *funcframework.Config) (int, error) {
+	dir, err := os.MkdirTemp("", "ff-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	bin, err := build(cfg.Source, dir)
+	if err != nil {
+		return 0, err
+	}
+	cmd := exec.Command(bin, serverArgs(cfg)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	return wait(cmd)
+}
+
+// build builds a binary serving the functions of the package in source in dir
+// and returns its path.
+func build(source, dir string) (string, error) {
+	pkg, err := loadFunctionPackage(source)
+	if err != nil {
+		return "", err
+	}
+	modDir := filepath.Join(dir, "wrapper")
+	if err := os.Mkdir(modDir, 0755); err != nil {
+		return "", err
+	}
+	if err := writeWrapperModule(modDir, pkg); err != nil {
+		return "", err
+	}
+	bin := filepath.Join(dir, "server")
+	if err := buildWrapperModule(modDir, bin); err != nil {
+		return "", err
+	}
+	return bin, nil
+}
+
+// serverArgs returns the flags passing cfg to the server.
+func serverArgs(cfg *funcframework.Config) []string {
+	return []string{
+		"--target=" + cfg.Target,
+		"--port=" + cfg.Port,
+		"--signature-type=" + cfg.SignatureType,
+		"--debug=" + strconv.FormatBool(cfg.Debug),
+	}
+}
+
+// wait waits for cmd to exit, forwarding SIGINT and SIGTERM to it, and
+// returns its exit code.
+func wait(cmd *exec.Cmd) (int, error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, err
+}
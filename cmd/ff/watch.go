@@ -0,0 +1,241 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
+	"github.com/GoogleCloudPlatform/functions-framework-go/internal/netutil"
+)
+
+const (
+	// pollInterval is how often the sources are checked for changes.
+	pollInterval = 500 * time.Millisecond
+	// serverStartTimeout is how long a new server may take to be ready to
+	// serve, which includes running the start hooks of the function, limited
+	// to one minute by default.
+	serverStartTimeout = 2 * time.Minute
+)
+
+// watch builds and runs the functions configured by cfg, and rebuilds and
+// restarts them whenever a Go file or the go.mod or go.sum file of their
+// module changes, until ff receives SIGINT or SIGTERM.
+//
+// ff owns the listening socket, which every server inherits, so that the
+// socket stays open across restarts: the old server keeps accepting
+// connections until the new one is ready to serve, then finishes its
+// in-flight requests before exiting. If a build fails, or the new server
+// exits before it is ready, the errors are printed and the old server keeps
+// running.
+func watch(cfg *funcframework.Config) error {
+	dir, err := os.MkdirTemp("", "ff-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	pkg, err := loadFunctionPackage(cfg.Source)
+	if err != nil {
+		return err
+	}
+	l, err := netutil.Listen("", cfg.Port)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	lf, err := l.(interface{ File() (*os.File, error) }).File()
+	if err != nil {
+		return err
+	}
+	defer lf.Close()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	var current *server
+	restart := func() {
+		buildDir, err := os.MkdirTemp(dir, "build-")
+		if err != nil {
+			log.Print(err)
+			return
+		}
+		bin, err := build(cfg.Source, buildDir)
+		if err != nil {
+			log.Print(err)
+			os.RemoveAll(buildDir)
+			return
+		}
+		next, err := startServer(bin, serverArgs(cfg), lf)
+		if err != nil {
+			log.Printf("Failed to start the server: %v", err)
+			if current != nil {
+				log.Print("The previous build keeps serving")
+			}
+			os.RemoveAll(buildDir)
+			return
+		}
+		if old := current; old != nil {
+			go func() {
+				old.stop()
+				os.RemoveAll(old.dir)
+			}()
+		}
+		next.dir = buildDir
+		current = next
+	}
+
+	state, err := sourceState(pkg.Module.Dir)
+	if err != nil {
+		return err
+	}
+	log.Printf("Watching %s for changes", pkg.Module.Dir)
+	restart()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-signals:
+			if current != nil {
+				current.stop()
+			}
+			return nil
+		case <-ticker.C:
+			next, err := sourceState(pkg.Module.Dir)
+			if err != nil {
+				log.Printf("Failed to check for changes: %v", err)
+				continue
+			}
+			if next == state {
+				continue
+			}
+			state = next
+			log.Print("Change detected, rebuilding")
+			restart()
+		}
+	}
+}
+
+// server is a running server process.
+type server struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+	// err is the error returned by cmd.Wait, set before done is closed.
+	err error
+	// dir is the directory the server was built in.
+	dir string
+}
+
+// startServer runs the server binary bin with args, serving connections
+// accepted on the listener l, and waits until the server is ready to serve
+// them. An error is returned if the server exits first, or is not ready
+// within serverStartTimeout, in which case it is stopped.
+func startServer(bin string, args []string, l *os.File) (*server, error) {
+	ready, readyWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer ready.Close()
+
+	cmd := exec.Command(bin, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// The extra files are file descriptors 3 and 4 in the child process.
+	cmd.ExtraFiles = []*os.File{l, readyWriter}
+	cmd.Env = append(os.Environ(), listenerFDEnv+"=3", readyFDEnv+"=4")
+	err = cmd.Start()
+	// Only the server keeps the write end open, so that reading from the
+	// pipe fails once it exits.
+	readyWriter.Close()
+	if err != nil {
+		return nil, err
+	}
+	s := &server{cmd: cmd, done: make(chan struct{})}
+	go func() {
+		s.err = cmd.Wait()
+		close(s.done)
+	}()
+
+	readyc := make(chan error, 1)
+	go func() {
+		_, err := ready.Read(make([]byte, 1))
+		readyc <- err
+	}()
+	select {
+	case err := <-readyc:
+		if err == nil {
+			return s, nil
+		}
+		<-s.done
+		if s.err != nil {
+			return nil, fmt.Errorf("server exited before serving: %v", s.err)
+		}
+		return nil, errors.New("server exited before serving")
+	case <-time.After(serverStartTimeout):
+		s.stop()
+		return nil, fmt.Errorf("server not ready to serve after %v", serverStartTimeout)
+	}
+}
+
+// stop shuts the server down gracefully and waits for it to exit.
+func (s *server) stop() {
+	s.cmd.Process.Signal(syscall.SIGTERM)
+	<-s.done
+}
+
+// sourceState returns a fingerprint of the Go files, go.mod and go.sum files
+// under dir, which changes when any of them is added, removed or modified.
+// Hidden directories, testdata directories and test files are skipped.
+func sourceState(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != dir && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if (!strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go")) && name != "go.mod" && name != "go.sum" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
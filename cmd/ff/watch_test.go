@@ -0,0 +1,151 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSourceState(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/userfn\n")
+	write("fn.go", "package userfn\n")
+
+	state := func() string {
+		t.Helper()
+		s, err := sourceState(dir)
+		if err != nil {
+			t.Fatalf("sourceState(): %v", err)
+		}
+		return s
+	}
+
+	tcs := []struct {
+		name    string
+		file    string
+		changed bool
+	}{
+		{name: "go file", file: "fn.go", changed: true},
+		{name: "new go file in a package", file: "internal/util.go", changed: true},
+		{name: "go.sum", file: "go.sum", changed: true},
+		{name: "test file", file: "fn_test.go"},
+		{name: "other file", file: "README.md"},
+		{name: "testdata", file: "testdata/fn.go"},
+		{name: "hidden directory", file: ".git/fn.go"},
+		{name: "vendor", file: "vendor/example.com/dep/dep.go"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			before := state()
+			write(tc.file, "package userfn\n\nvar changed = true\n")
+			if changed := state() != before; changed != tc.changed {
+				t.Errorf("state changed = %v after writing %s, want %v", changed, tc.file, tc.changed)
+			}
+		})
+	}
+}
+
+func TestStartServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	lf, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lf.Close()
+
+	tcs := []struct {
+		name    string
+		source  string
+		wantErr bool
+	}{
+		{
+			name: "ready",
+		},
+		{
+			name: "start hook fails",
+			source: `package userfn
+
+import (
+	"context"
+	"errors"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
+)
+
+func init() {
+	funcframework.OnStart(func(context.Context) error {
+		return errors.New("start hook failed")
+	})
+}
+`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			source := writeTestModule(t)
+			if tc.source != "" {
+				if err := os.WriteFile(filepath.Join(source, "hook.go"), []byte(tc.source), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			bin, err := build(source, t.TempDir())
+			if err != nil {
+				t.Fatalf("build(): %v", err)
+			}
+
+			s, err := startServer(bin, []string{"--target=Hello"}, lf)
+			if tc.wantErr {
+				if err == nil {
+					s.stop()
+					t.Fatalf("startServer() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("startServer(): %v", err)
+			}
+			defer s.stop()
+			// The server serves as soon as startServer returns.
+			resp, err := http.Get(fmt.Sprintf("http://%s/", l.Addr()))
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("response status = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command ffevent sends a sample event to a function running locally:
+//
+//	go run github.com/GoogleCloudPlatform/functions-framework-go/cmd/ffevent --mode structured storage
+//
+// The sample events are a Pub/Sub message publication (pubsub), a Cloud
+// Storage object finalization (storage), a Firestore document write
+// (firestore) and a Firebase Authentication user creation (auth). They are
+// sent as a background event (--mode legacy), or as a CloudEvent in binary
+// (--mode binary) or structured (--mode structured) content mode. The
+// CloudEvents are converted from the background events the way the framework
+// converts them, so either kind of function receives the same event whatever
+// the mode.
+//
+// The response status and body are printed, and ffevent exits with status 1
+// if the function fails.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("ffevent: ")
+
+	url := flag.String("url", "http://localhost:8080", "URL of the function")
+	mode := flag.String("mode", legacyMode, fmt.Sprintf("content mode of the event: %s, %s or %s", legacyMode, binaryMode, structuredMode))
+	data := flag.String("data", "", "JSON object replacing the data of the sample event")
+	dump := flag.Bool("print", false, "print the request instead of sending it")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: ffevent [flags] %s\n\nSends a sample event to a function.\n\nFlags:\n", strings.Join(sampleNames(), "|"))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	event, err := backgroundEvent(flag.Arg(0), time.Now(), *data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	req, err := newRequest(*url, *mode, event)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *dump {
+		b, err := httputil.DumpRequestOut(req, true)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(b)
+		fmt.Println()
+		return
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+	fmt.Println(resp.Status)
+	io.Copy(os.Stdout, resp.Body)
+	if resp.StatusCode >= 300 {
+		os.Exit(1)
+	}
+}
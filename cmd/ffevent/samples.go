@@ -0,0 +1,216 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/internal/invoker"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+
+	// The framework provides the event conversion when it is initialized.
+	_ "github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
+)
+
+// Modes in which events are sent.
+const (
+	legacyMode     = "legacy"
+	binaryMode     = "binary"
+	structuredMode = "structured"
+)
+
+// sample is a sample background event. Events sent in a CloudEvent mode are
+// converted from the background event by the framework's own converter, so
+// that they match the events the framework delivers.
+type sample struct {
+	eventType string
+	// resource is either the resource name or a map describing the resource.
+	resource interface{}
+	data     string
+}
+
+var samples = map[string]sample{
+	"pubsub": {
+		eventType: "google.pubsub.topic.publish",
+		resource: map[string]string{
+			"service": "pubsub.googleapis.com",
+			"name":    "projects/sample-project/topics/sample-topic",
+			"type":    "type.googleapis.com/google.pubsub.v1.PubsubMessage",
+		},
+		data: `{
+			"@type": "type.googleapis.com/google.pubsub.v1.PubsubMessage",
+			"attributes": {"origin": "ffevent"},
+			"data": "SGVsbG8sIFdvcmxkIQ=="
+		}`,
+	},
+	"storage": {
+		eventType: "google.storage.object.finalize",
+		resource: map[string]string{
+			"service": "storage.googleapis.com",
+			"name":    "projects/_/buckets/sample-bucket/objects/folder/sample.txt",
+			"type":    "storage#object",
+		},
+		data: `{
+			"bucket": "sample-bucket",
+			"contentType": "text/plain",
+			"crc32c": "rTVTeQ==",
+			"etag": "CNHZkbuF/ugCEAE=",
+			"generation": "1587627537231057",
+			"id": "sample-bucket/folder/sample.txt/1587627537231057",
+			"kind": "storage#object",
+			"md5Hash": "kF8MuJ5+CTJxvyhHS1xzRg==",
+			"mediaLink": "https://www.googleapis.com/download/storage/v1/b/sample-bucket/o/folder%2Fsample.txt?generation=1587627537231057&alt=media",
+			"metageneration": "1",
+			"name": "folder/sample.txt",
+			"selfLink": "https://www.googleapis.com/storage/v1/b/sample-bucket/o/folder/sample.txt",
+			"size": "352",
+			"storageClass": "STANDARD",
+			"timeCreated": "2020-04-23T07:38:57.230Z",
+			"timeStorageClassUpdated": "2020-04-23T07:38:57.230Z",
+			"updated": "2020-04-23T07:38:57.230Z"
+		}`,
+	},
+	"firestore": {
+		eventType: "providers/cloud.firestore/eventTypes/document.write",
+		resource:  "projects/sample-project/databases/(default)/documents/users/alice",
+		data: `{
+			"oldValue": {},
+			"updateMask": {},
+			"value": {
+				"createTime": "2020-04-23T09:58:53.211035Z",
+				"fields": {
+					"name": {"stringValue": "Alice"},
+					"visits": {"integerValue": "1"}
+				},
+				"name": "projects/sample-project/databases/(default)/documents/users/alice",
+				"updateTime": "2020-04-23T09:58:53.211035Z"
+			}
+		}`,
+	},
+	"auth": {
+		eventType: "providers/firebase.auth/eventTypes/user.create",
+		resource:  "projects/sample-project",
+		data: `{
+			"email": "alice@example.com",
+			"metadata": {
+				"createdAt": "2020-05-26T10:42:27Z",
+				"lastSignedInAt": "2020-05-26T10:42:27Z"
+			},
+			"providerData": [
+				{
+					"email": "alice@example.com",
+					"providerId": "password",
+					"uid": "alice@example.com"
+				}
+			],
+			"uid": "UUpby3s4spZre6kHsgVSPetzQ8l2"
+		}`,
+	},
+}
+
+// sampleNames returns the names of the sample events, sorted.
+func sampleNames() []string {
+	var names []string
+	for name := range samples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// backgroundEvent returns the JSON encoded sample background event name,
+// occurring at t. If data is not empty, it replaces the data of the sample.
+func backgroundEvent(name string, t time.Time, data string) ([]byte, error) {
+	s, ok := samples[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown event %q, want one of %v", name, sampleNames())
+	}
+	if data == "" {
+		data = s.data
+	}
+	var d map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &d); err != nil {
+		return nil, fmt.Errorf("invalid event data, want a JSON object: %v", err)
+	}
+
+	type context struct {
+		EventID   string      `json:"eventId"`
+		Timestamp string      `json:"timestamp"`
+		EventType string      `json:"eventType"`
+		Resource  interface{} `json:"resource"`
+	}
+	return json.Marshal(struct {
+		Context context                `json:"context"`
+		Data    map[string]interface{} `json:"data"`
+	}{
+		Context: context{
+			EventID:   strconv.FormatInt(t.UnixNano(), 10),
+			Timestamp: t.UTC().Format("2006-01-02T15:04:05.000Z"),
+			EventType: s.eventType,
+			Resource:  s.resource,
+		},
+		Data: d,
+	})
+}
+
+// newRequest returns a request sending the background event to url in mode.
+func newRequest(url, mode string, event []byte) (*http.Request, error) {
+	if mode == legacyMode {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(event))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	}
+
+	structured, err := invoker.BackgroundEventToCloudEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	switch mode {
+	case structuredMode:
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(structured))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/cloudevents+json")
+		return req, nil
+	case binaryMode:
+		ce := cloudevents.NewEvent()
+		if err := json.Unmarshal(structured, &ce); err != nil {
+			return nil, fmt.Errorf("invalid CloudEvent %s: %v", structured, err)
+		}
+		req, err := http.NewRequest(http.MethodPost, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		ctx := binding.WithForceBinary(context.Background())
+		if err := cehttp.WriteRequest(ctx, binding.ToMessage(&ce), req); err != nil {
+			return nil, err
+		}
+		return req, nil
+	}
+	return nil, fmt.Errorf("unknown mode %q, want %q, %q or %q", mode, legacyMode, binaryMode, structuredMode)
+}
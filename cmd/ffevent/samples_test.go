@@ -0,0 +1,120 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/functions/metadata"
+	"github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+func TestSamples(t *testing.T) {
+	ctx := context.Background()
+	events := make(chan string, 1)
+	if err := funcframework.RegisterEventFunctionContext(ctx, "/event", func(ctx context.Context, data map[string]interface{}) error {
+		m, err := metadata.FromContext(ctx)
+		if err != nil {
+			return err
+		}
+		events <- m.EventType
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	cloudEvents := make(chan string, 1)
+	if err := funcframework.RegisterCloudEventFunctionContext(ctx, "/cloudevent", func(ctx context.Context, e cloudevents.Event) error {
+		cloudEvents <- e.Type()
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go funcframework.Start("", funcframework.WithListener(l))
+
+	tcs := []struct {
+		name          string
+		wantEvent     string
+		wantCloudType string
+	}{
+		{name: "pubsub", wantEvent: "google.pubsub.topic.publish", wantCloudType: "google.cloud.pubsub.topic.v1.messagePublished"},
+		{name: "storage", wantEvent: "google.storage.object.finalize", wantCloudType: "google.cloud.storage.object.v1.finalized"},
+		{name: "firestore", wantEvent: "providers/cloud.firestore/eventTypes/document.write", wantCloudType: "google.cloud.firestore.document.v1.written"},
+		{name: "auth", wantEvent: "providers/firebase.auth/eventTypes/user.create", wantCloudType: "google.firebase.auth.user.v1.created"},
+	}
+	for _, tc := range tcs {
+		for _, mode := range []string{legacyMode, binaryMode, structuredMode} {
+			t.Run(tc.name+"/"+mode, func(t *testing.T) {
+				event, err := backgroundEvent(tc.name, time.Now(), "")
+				if err != nil {
+					t.Fatalf("backgroundEvent(): %v", err)
+				}
+				for _, target := range []struct {
+					path string
+					got  chan string
+					want string
+				}{
+					{"/event", events, tc.wantEvent},
+					{"/cloudevent", cloudEvents, tc.wantCloudType},
+				} {
+					req, err := newRequest(fmt.Sprintf("http://%s%s", l.Addr(), target.path), mode, event)
+					if err != nil {
+						t.Fatalf("newRequest(): %v", err)
+					}
+					resp, err := http.DefaultClient.Do(req)
+					if err != nil {
+						t.Fatalf("request: %v", err)
+					}
+					body, _ := io.ReadAll(resp.Body)
+					resp.Body.Close()
+					if resp.StatusCode != http.StatusOK {
+						t.Fatalf("%s responded %s: %s", target.path, resp.Status, body)
+					}
+					if got := <-target.got; got != target.want {
+						t.Errorf("%s received event type %q, want %q", target.path, got, target.want)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSampleErrors(t *testing.T) {
+	if _, err := backgroundEvent("pubsub", time.Now(), `"hello"`); err == nil || !strings.Contains(err.Error(), "want a JSON object") {
+		t.Errorf("backgroundEvent() with invalid data error = %v, want invalid event data", err)
+	}
+	if _, err := backgroundEvent("bigquery", time.Now(), ""); err == nil || !strings.Contains(err.Error(), "unknown event") {
+		t.Errorf("backgroundEvent() with unknown event error = %v, want unknown event", err)
+	}
+	event, err := backgroundEvent("pubsub", time.Now(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newRequest("http://localhost:8080", "batch", event); err == nil || !strings.Contains(err.Error(), "unknown mode") {
+		t.Errorf("newRequest() with unknown mode error = %v, want unknown mode", err)
+	}
+}
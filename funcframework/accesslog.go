@@ -0,0 +1,131 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithAccessLog logs one entry to stderr after every invocation of every
+// function, with the function name, request method and URL, response status,
+// X-Google-Status value, latency, response size and execution ID.
+//
+// When running on GCF, entries are structured with the request in the Cloud
+// Logging httpRequest format so that they are displayed like request logs.
+// Locally, entries are compact lines such as:
+//
+//	HelloWorld: GET /?name=Gopher 200 1.2ms 13B execution_id=18c9e1d1b0f5a7
+func WithAccessLog() ServerOption {
+	return func(c *serverConfig) {
+		c.accessLog = true
+	}
+}
+
+// accessLogEvent is the structured access log entry written when running on
+// GCF.
+type accessLogEvent struct {
+	Severity     LogSeverity       `json:"severity"`
+	Message      string            `json:"message"`
+	HTTPRequest  *httpRequestLog   `json:"httpRequest"`
+	Trace        string            `json:"logging.googleapis.com/trace,omitempty"`
+	SpanID       string            `json:"logging.googleapis.com/spanId,omitempty"`
+	TraceSampled bool              `json:"logging.googleapis.com/trace_sampled,omitempty"`
+	Labels       map[string]string `json:"logging.googleapis.com/labels,omitempty"`
+}
+
+// logAccess wraps h, the handler serving the function called name, so that an
+// access log entry is written after every invocation.
+func logAccess(name string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r = addLoggingIDsToRequest(r)
+		sw := &statusRecorder{ResponseWriter: w}
+		h.ServeHTTP(sw, r)
+		latency := time.Since(start)
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		req := *newHTTPRequestLog(r)
+		req.Status = status
+		req.ResponseSize = strconv.FormatInt(sw.size, 10)
+		req.Latency = fmt.Sprintf("%.9fs", latency.Seconds())
+
+		var ids loggingIDs
+		if l := loggingIDsFromContext(r.Context()); l != nil {
+			ids = *l
+		}
+		googleStatus := sw.Header().Get(functionStatusHeader)
+
+		out := logOutput(r.Context())
+		if !currentConfig().onGCF() {
+			fmt.Fprintln(out, formatAccessLog(name, &req, googleStatus, latency, sw.size, ids.executionID))
+			return
+		}
+		writeAccessLogEvent(out, name, &req, googleStatus, ids)
+	})
+}
+
+// formatAccessLog returns the human-readable access log line used locally.
+func formatAccessLog(name string, req *httpRequestLog, googleStatus string, latency time.Duration, size int64, executionID string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s %s %d", name, req.RequestMethod, req.RequestURL, req.Status)
+	if googleStatus != "" {
+		fmt.Fprintf(&b, " (%s)", googleStatus)
+	}
+	fmt.Fprintf(&b, " %v %dB", latency.Round(time.Microsecond), size)
+	if executionID != "" {
+		fmt.Fprintf(&b, " execution_id=%s", executionID)
+	}
+	return b.String()
+}
+
+func writeAccessLogEvent(w io.Writer, name string, req *httpRequestLog, googleStatus string, ids loggingIDs) {
+	severity := SeverityInfo
+	switch {
+	case req.Status >= http.StatusInternalServerError:
+		severity = SeverityError
+	case req.Status >= http.StatusBadRequest:
+		severity = SeverityWarning
+	}
+	labels := map[string]string{"function_name": name}
+	if ids.executionID != "" {
+		labels["execution_id"] = ids.executionID
+	}
+	if googleStatus != "" {
+		labels["google_status"] = googleStatus
+	}
+	event := accessLogEvent{
+		Severity:     severity,
+		Message:      fmt.Sprintf("%s %s %d", req.RequestMethod, req.RequestURL, req.Status),
+		HTTPRequest:  req,
+		Trace:        ids.trace,
+		SpanID:       ids.spanID,
+		TraceSampled: ids.traceSampled,
+		Labels:       labels,
+	}
+	marshalled, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(w, string(marshalled))
+}
@@ -0,0 +1,157 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+)
+
+func TestAccessLog(t *testing.T) {
+	tcs := []struct {
+		name     string
+		kService string
+		fn       func(http.ResponseWriter, *http.Request)
+		// wantLocal is a regular expression matching the local access log line.
+		wantLocal string
+		wantEvent accessLogEvent
+	}{
+		{
+			name: "local",
+			fn: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "Hello World!")
+			},
+			wantLocal: `^fn: GET /fn\?q=1 200 [\d.]+[µnm]?s 12B execution_id=exec$`,
+		},
+		{
+			name: "local panic",
+			fn: func(w http.ResponseWriter, r *http.Request) {
+				panic("intentional panic for test")
+			},
+			wantLocal: `^fn: GET /fn\?q=1 500 \(crash\) [\d.]+[µnm]?s \d+B execution_id=exec$`,
+		},
+		{
+			name:     "GCF",
+			kService: "test-service",
+			fn: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, "Not found")
+			},
+			wantEvent: accessLogEvent{
+				Severity: SeverityWarning,
+				Message:  "GET /fn?q=1 404",
+				HTTPRequest: &httpRequestLog{
+					RequestMethod: "GET",
+					RequestURL:    "/fn?q=1",
+					UserAgent:     "test-agent",
+					RemoteIP:      "192.0.2.1:1234",
+					Protocol:      "HTTP/1.1",
+					Status:        http.StatusNotFound,
+					ResponseSize:  "9",
+				},
+				Trace: "0123456789abcdef",
+				Labels: map[string]string{
+					"function_name": "fn",
+					"execution_id":  "exec",
+				},
+			},
+		},
+		{
+			name:     "GCF panic",
+			kService: "test-service",
+			fn: func(w http.ResponseWriter, r *http.Request) {
+				panic("intentional panic for test")
+			},
+			wantEvent: accessLogEvent{
+				Severity: SeverityError,
+				Message:  "GET /fn?q=1 500",
+				HTTPRequest: &httpRequestLog{
+					RequestMethod: "GET",
+					RequestURL:    "/fn?q=1",
+					UserAgent:     "test-agent",
+					RemoteIP:      "192.0.2.1:1234",
+					Protocol:      "HTTP/1.1",
+					Status:        http.StatusInternalServerError,
+					ResponseSize:  fmt.Sprint(len(fmt.Sprintf(panicMessageTmpl, "user function execution") + "\n")),
+				},
+				Trace: "0123456789abcdef",
+				Labels: map[string]string{
+					"function_name": "fn",
+					"execution_id":  "exec",
+					"google_status": "crash",
+				},
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer cleanup()
+			t.Setenv("K_SERVICE", tc.kService)
+
+			functions.HTTP("fn", tc.fn)
+			server, err := initServer(WithAccessLog())
+			if err != nil {
+				t.Fatalf("initServer(): %v", err)
+			}
+
+			origStderr := os.Stderr
+			r, w, _ := os.Pipe()
+			os.Stderr = w
+			defer func() { os.Stderr = origStderr }()
+
+			req := httptest.NewRequest("GET", "/fn?q=1", nil)
+			req.Header.Set("Function-Execution-Id", "exec")
+			req.Header.Set("X-Cloud-Trace-Context", "0123456789abcdef")
+			req.Header.Set("User-Agent", "test-agent")
+			server.ServeHTTP(httptest.NewRecorder(), req)
+
+			w.Close()
+			stderr, _ := io.ReadAll(r)
+			lines := strings.Split(strings.TrimSpace(string(stderr)), "\n")
+			last := lines[len(lines)-1]
+
+			if tc.kService == "" {
+				if !regexp.MustCompile(tc.wantLocal).MatchString(last) {
+					t.Errorf("access log = %q, want match for %q", last, tc.wantLocal)
+				}
+				return
+			}
+
+			var got accessLogEvent
+			if err := json.Unmarshal([]byte(last), &got); err != nil {
+				t.Fatalf("access log %q is not structured: %v", last, err)
+			}
+			if !strings.HasSuffix(got.HTTPRequest.Latency, "s") {
+				t.Errorf("access log latency = %q, want duration in seconds", got.HTTPRequest.Latency)
+			}
+			got.HTTPRequest.Latency = ""
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(tc.wantEvent)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("access log = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// logCaptureInstance is set by EnableLogCapture. If nil, the standard log
+// package, stdout and stderr are left untouched.
+var logCaptureInstance *logCapture
+
+// EnableLogCapture redirects the output of the standard log package, as well
+// as anything written to os.Stdout and os.Stderr, to structured log events.
+// This lets logs written with log.Printf or fmt.Println, by the function or by
+// the libraries it uses, be correlated with invocations like those written
+// with LogWriter.
+//
+// While exactly one invocation is in progress, each log event carries the
+// trace, span and execution IDs of that invocation. When several invocations
+// run concurrently the invocation a line belongs to cannot be known, so log
+// events are written without IDs. Lines that are already JSON objects, such
+// as those written by LogWriter or Logger, are passed through unchanged.
+//
+// The timestamp flags of the standard logger are cleared, as Cloud Logging
+// records the time of every entry. Output of the log package is written
+// synchronously, whereas writes to os.Stdout and os.Stderr are forwarded in
+// the background: their lines are attributed to the invocation in progress
+// when they are forwarded and may be lost if the process exits abruptly.
+//
+// EnableLogCapture must be called before Start.
+func EnableLogCapture() error {
+	if logCaptureInstance != nil {
+		return fmt.Errorf("log capture is already enabled")
+	}
+	c, err := newLogCapture()
+	if err != nil {
+		return err
+	}
+	logCaptureInstance = c
+	return nil
+}
+
+// logCapture holds the state of the process-wide redirection installed by
+// EnableLogCapture.
+type logCapture struct {
+	mu sync.Mutex
+	// active holds the logging IDs of the invocations in progress.
+	active map[*loggingIDs]struct{}
+
+	stdout, stderr     *os.File
+	stdoutW, stderrW   *os.File
+	origLogOutput      io.Writer
+	origLogFlags       int
+	wg                 sync.WaitGroup
+	stdoutMu, stderrMu sync.Mutex
+}
+
+func newLogCapture() (*logCapture, error) {
+	c := &logCapture{
+		active:        map[*loggingIDs]struct{}{},
+		stdout:        os.Stdout,
+		stderr:        os.Stderr,
+		origLogOutput: log.Writer(),
+		origLogFlags:  log.Flags(),
+	}
+
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("capturing stdout: %v", err)
+	}
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		stdoutR.Close()
+		stdoutW.Close()
+		return nil, fmt.Errorf("capturing stderr: %v", err)
+	}
+	c.stdoutW, c.stderrW = stdoutW, stderrW
+
+	c.wg.Add(2)
+	go c.forward(stdoutR, c.stdout, &c.stdoutMu)
+	go c.forward(stderrR, c.stderr, &c.stderrMu)
+
+	os.Stdout, os.Stderr = stdoutW, stderrW
+	// Cloud Logging timestamps every entry, so the standard logger's own
+	// timestamp prefix is redundant.
+	log.SetFlags(log.Flags() &^ (log.Ldate | log.Ltime | log.Lmicroseconds))
+	log.SetOutput(&capturedLogWriter{c})
+	return c, nil
+}
+
+// close restores the standard log package, os.Stdout and os.Stderr and waits
+// for pending output to be forwarded.
+func (c *logCapture) close() {
+	log.SetOutput(c.origLogOutput)
+	log.SetFlags(c.origLogFlags)
+	os.Stdout, os.Stderr = c.stdout, c.stderr
+	c.stdoutW.Close()
+	c.stderrW.Close()
+	c.wg.Wait()
+}
+
+// track wraps h so that the logging IDs of each invocation are known to the
+// capture while the invocation is in progress.
+func (c *logCapture) track(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = addLoggingIDsToRequest(r)
+		ids := loggingIDsFromContext(r.Context())
+		c.mu.Lock()
+		c.active[ids] = struct{}{}
+		c.mu.Unlock()
+		defer func() {
+			c.mu.Lock()
+			delete(c.active, ids)
+			c.mu.Unlock()
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// currentLoggingIDs returns the logging IDs of the invocation in progress if
+// there is exactly one.
+func (c *logCapture) currentLoggingIDs() loggingIDs {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.active) != 1 {
+		return loggingIDs{}
+	}
+	for ids := range c.active {
+		return *ids
+	}
+	return loggingIDs{}
+}
+
+// forward writes each line read from r to dst as a structured log event until
+// r is closed.
+func (c *logCapture) forward(r *os.File, dst io.Writer, mu *sync.Mutex) {
+	defer c.wg.Done()
+	defer r.Close()
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			c.writeLine(dst, mu, line)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// writeLine writes line to dst as a structured log event. Empty lines, such
+// as those printed to flush logs on GCF, are dropped.
+func (c *logCapture) writeLine(dst io.Writer, mu *sync.Mutex, line []byte) {
+	line = bytes.TrimRight(line, "\r\n")
+	if len(bytes.TrimSpace(line)) == 0 {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if line[0] == '{' && json.Valid(line) {
+		dst.Write(append(line[:len(line):len(line)], '\n'))
+		return
+	}
+	w := &structuredLogWriter{w: dst}
+	w.writeStructuredLog(c.currentLoggingIDs(), string(line))
+}
+
+// capturedLogWriter is the output of the standard log package while log
+// capture is enabled.
+type capturedLogWriter struct {
+	c *logCapture
+}
+
+func (w *capturedLogWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		w.c.writeLine(w.c.stderr, &w.c.stderrMu, line)
+	}
+	return len(p), nil
+}
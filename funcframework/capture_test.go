@@ -0,0 +1,132 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+)
+
+func TestLogCapture(t *testing.T) {
+	defer cleanup()
+
+	origStdout, origStderr := os.Stdout, os.Stderr
+	stdoutR, stdoutW, _ := os.Pipe()
+	stderrR, stderrW, _ := os.Pipe()
+	os.Stdout, os.Stderr = stdoutW, stderrW
+	defer func() { os.Stdout, os.Stderr = origStdout, origStderr }()
+
+	if err := EnableLogCapture(); err != nil {
+		t.Fatalf("EnableLogCapture(): %v", err)
+	}
+	c := logCaptureInstance
+	defer func() { logCaptureInstance = nil }()
+	if err := EnableLogCapture(); err == nil {
+		t.Errorf("second EnableLogCapture() succeeded, want error")
+	}
+
+	functions.HTTP("fn", func(w http.ResponseWriter, r *http.Request) {
+		log.Print("from the log package")
+		fmt.Println("from stdout")
+		fmt.Println(`{"message":"already structured"}`)
+		fmt.Fprintln(w, "Hello World!")
+	})
+	server, err := initServer()
+	if err != nil {
+		t.Fatalf("initServer(): %v", err)
+	}
+	req := httptest.NewRequest("GET", "/fn", nil)
+	req.Header.Set("Function-Execution-Id", "exec")
+	server.ServeHTTP(httptest.NewRecorder(), req)
+
+	log.Print("outside of any invocation")
+
+	c.close()
+	stdoutW.Close()
+	stderrW.Close()
+	stdout, _ := io.ReadAll(stdoutR)
+	stderr, _ := io.ReadAll(stderrR)
+
+	parse := func(output []byte) map[string]map[string]interface{} {
+		entries := map[string]map[string]interface{}{}
+		for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+			if line == "" {
+				continue
+			}
+			var entry map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("captured output %q is not structured: %v", line, err)
+			}
+			entries[entry["message"].(string)] = entry
+		}
+		return entries
+	}
+	stdoutEntries, stderrEntries := parse(stdout), parse(stderr)
+
+	for _, msg := range []string{"from stdout", "already structured"} {
+		if _, ok := stdoutEntries[msg]; !ok {
+			t.Errorf("stdout %q is missing %q", stdout, msg)
+		}
+	}
+	if _, ok := stdoutEntries["already structured"]["logging.googleapis.com/labels"]; ok {
+		t.Errorf("structured line was modified: %v", stdoutEntries["already structured"])
+	}
+
+	entry, ok := stderrEntries["from the log package"]
+	if !ok {
+		t.Fatalf("stderr %q is missing log package output", stderr)
+	}
+	if got := entry["logging.googleapis.com/labels"]; fmt.Sprint(got) != "map[execution_id:exec]" {
+		t.Errorf("log package output labels = %v, want execution_id exec", got)
+	}
+	entry, ok = stderrEntries["outside of any invocation"]
+	if !ok {
+		t.Fatalf("stderr %q is missing log package output", stderr)
+	}
+	if got, ok := entry["logging.googleapis.com/labels"]; ok {
+		t.Errorf("log package output outside of an invocation has labels %v", got)
+	}
+
+	if os.Stdout != stdoutW || os.Stderr != stderrW {
+		t.Errorf("close() did not restore os.Stdout and os.Stderr")
+	}
+}
+
+func TestLogCaptureConcurrentInvocations(t *testing.T) {
+	c := &logCapture{active: map[*loggingIDs]struct{}{}}
+	first, second := &loggingIDs{executionID: "first"}, &loggingIDs{executionID: "second"}
+
+	c.active[first] = struct{}{}
+	if got := c.currentLoggingIDs().executionID; got != "first" {
+		t.Errorf("currentLoggingIDs() with one invocation = %q, want %q", got, "first")
+	}
+	c.active[second] = struct{}{}
+	if got := c.currentLoggingIDs(); got != (loggingIDs{}) {
+		t.Errorf("currentLoggingIDs() with concurrent invocations = %+v, want none", got)
+	}
+	delete(c.active, first)
+	if got := c.currentLoggingIDs().executionID; got != "second" {
+		t.Errorf("currentLoggingIDs() after the first invocation ended = %q, want %q", got, "second")
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/internal/registry"
+)
+
+// retryAfterSeconds is the Retry-After value of rejected invocations.
+const retryAfterSeconds = 1
+
+// concurrencyStatusPath is set by EnableConcurrencyStatus. If empty, the
+// concurrency status is not served.
+var concurrencyStatusPath string
+
+var concurrencySlotContextKey contextKey = "concurrencySlot"
+
+var (
+	limitersMu sync.Mutex
+	// limiters are the concurrency limiters of the functions served by the
+	// last server created by initServerWithConfig.
+	limiters []*concurrencyLimiter
+)
+
+// concurrencyLimiter bounds the number of concurrent invocations of a
+// function, optionally queueing the invocations beyond the limit.
+type concurrencyLimiter struct {
+	name     string
+	slots    chan struct{}
+	maxQueue int
+	maxWait  time.Duration
+
+	mu     sync.Mutex
+	queued int
+}
+
+// concurrencyStatus is the status of a concurrencyLimiter served by
+// EnableConcurrencyStatus.
+type concurrencyStatus struct {
+	InFlight       int `json:"inFlight"`
+	Queued         int `json:"queued"`
+	MaxConcurrency int `json:"maxConcurrency"`
+	MaxQueueLength int `json:"maxQueueLength"`
+}
+
+// withConcurrencyLimit wraps h, the handler serving fn, so that the number of
+// concurrent invocations does not exceed fn.MaxConcurrency.
+func withConcurrencyLimit(fn *registry.RegisteredFunction, h http.Handler) http.Handler {
+	if fn.MaxConcurrency <= 0 {
+		return h
+	}
+	l := &concurrencyLimiter{
+		name:     functionName(fn),
+		slots:    make(chan struct{}, fn.MaxConcurrency),
+		maxQueue: fn.MaxQueueLength,
+		maxWait:  fn.MaxQueueWait,
+	}
+	limitersMu.Lock()
+	limiters = append(limiters, l)
+	limitersMu.Unlock()
+	return l.limit(h)
+}
+
+func (l *concurrencyLimiter) limit(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status := l.acquire(r); status != 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+			http.Error(w, fmt.Sprintf("Function %q is at its concurrency limit.", l.name), status)
+			return
+		}
+		slot := &concurrencySlot{release: l.release}
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), concurrencySlotContextKey, slot)))
+		if !slot.handedOff {
+			slot.release()
+		}
+	})
+}
+
+// concurrencySlot is the concurrency slot held by an invocation. It is
+// released once the handler wrapped by the limiter returns, unless it was
+// handed off to whatever keeps running the function after that.
+type concurrencySlot struct {
+	release   func()
+	handedOff bool
+}
+
+// handOffConcurrencySlot takes over the concurrency slot held by the
+// invocation with ctx, if any, from the limiter: the returned function must be
+// called once the function returns, even if the handler wrapped by the limiter
+// returned before. It must be called by the handler wrapped by the limiter,
+// before it returns.
+func handOffConcurrencySlot(ctx context.Context) (release func()) {
+	slot, ok := ctx.Value(concurrencySlotContextKey).(*concurrencySlot)
+	if !ok {
+		return func() {}
+	}
+	slot.handedOff = true
+	return slot.release
+}
+
+// acquire waits for a concurrency slot for r. It returns 0 once a slot is
+// acquired, or the status code to reject r with.
+func (l *concurrencyLimiter) acquire(r *http.Request) int {
+	select {
+	case l.slots <- struct{}{}:
+		return 0
+	default:
+	}
+
+	l.mu.Lock()
+	if l.queued >= l.maxQueue {
+		l.mu.Unlock()
+		return http.StatusTooManyRequests
+	}
+	l.queued++
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.queued--
+		l.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(l.maxWait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return 0
+	case <-timer.C:
+		return http.StatusServiceUnavailable
+	case <-r.Context().Done():
+		return http.StatusServiceUnavailable
+	}
+}
+
+func (l *concurrencyLimiter) release() {
+	<-l.slots
+}
+
+func (l *concurrencyLimiter) status() concurrencyStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return concurrencyStatus{
+		InFlight:       len(l.slots),
+		Queued:         l.queued,
+		MaxConcurrency: cap(l.slots),
+		MaxQueueLength: l.maxQueue,
+	}
+}
+
+// resetConcurrencyLimiters forgets the limiters of any previous server.
+func resetConcurrencyLimiters() {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	limiters = nil
+}
+
+// EnableConcurrencyStatus serves the number of in-flight and queued
+// invocations of each function with a concurrency limit as a JSON object at
+// path, keyed by function name. The path must not collide with the paths of
+// the registered functions. When a target function is served at "/", requests
+// to path are answered by the framework instead of the function.
+//
+// EnableConcurrencyStatus must be called before Start.
+func EnableConcurrencyStatus(path string) error {
+	if !strings.HasPrefix(path, "/") || path == "/" || strings.HasSuffix(path, "/") {
+		return fmt.Errorf("invalid concurrency status path %q: must start with '/' and must not end with '/'", path)
+	}
+	concurrencyStatusPath = path
+	return nil
+}
+
+// handleConcurrencyStatus serves the status of the concurrency limited
+// functions on server if enabled by EnableConcurrencyStatus. It fails if any
+// of the functions in fns is served at concurrencyStatusPath.
+func handleConcurrencyStatus(server *http.ServeMux, fns []*registry.RegisteredFunction) error {
+	if concurrencyStatusPath == "" {
+		return nil
+	}
+	for _, fn := range fns {
+		if fn.Path == concurrencyStatusPath || strings.HasPrefix(fn.Path, concurrencyStatusPath+"/") {
+			return fmt.Errorf("function path %q collides with concurrency status path %q", fn.Path, concurrencyStatusPath)
+		}
+	}
+	server.Handle(concurrencyStatusPath, concurrencyStatusHandler())
+	return nil
+}
+
+// concurrencyStatusHandler returns a handler serving the status of the
+// concurrency limited functions as JSON.
+func concurrencyStatusHandler() http.Handler {
+	limitersMu.Lock()
+	ls := append([]*concurrencyLimiter{}, limiters...)
+	limitersMu.Unlock()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		statuses := map[string]concurrencyStatus{}
+		for _, l := range ls {
+			statuses[l.name] = l.status()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(statuses)
+	})
+}
@@ -0,0 +1,313 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+)
+
+const testConcurrencyStatusPath = "/_ff/concurrency"
+
+func TestConcurrencyLimit(t *testing.T) {
+	tcs := []struct {
+		name    string
+		options []functions.Option
+		// release is whether the first invocation returns while the second
+		// one is waiting.
+		release    bool
+		wantStatus int
+		wantQueued int
+	}{
+		{
+			name:       "no queue",
+			options:    []functions.Option{functions.WithMaxConcurrency(1)},
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "queue wait exceeded",
+			options:    []functions.Option{functions.WithMaxConcurrency(1), functions.WithConcurrencyQueue(1, 100*time.Millisecond)},
+			wantStatus: http.StatusServiceUnavailable,
+			wantQueued: 1,
+		},
+		{
+			name:       "queued until a slot is released",
+			options:    []functions.Option{functions.WithMaxConcurrency(1), functions.WithConcurrencyQueue(1, time.Minute)},
+			release:    true,
+			wantStatus: http.StatusOK,
+			wantQueued: 1,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer cleanup()
+			defer func() { concurrencyStatusPath = "" }()
+
+			if err := EnableConcurrencyStatus(testConcurrencyStatusPath); err != nil {
+				t.Fatalf("EnableConcurrencyStatus(): %v", err)
+			}
+			started := make(chan struct{}, 2)
+			release := make(chan struct{})
+			functions.HTTP("fn", func(w http.ResponseWriter, r *http.Request) {
+				started <- struct{}{}
+				<-release
+			}, tc.options...)
+			server, err := initServer()
+			if err != nil {
+				t.Fatalf("initServer(): %v", err)
+			}
+			srv := httptest.NewServer(server)
+			defer srv.Close()
+
+			status := func() concurrencyStatus {
+				resp, err := http.Get(srv.URL + testConcurrencyStatusPath)
+				if err != nil {
+					t.Fatalf("http.Get(%q): %v", testConcurrencyStatusPath, err)
+				}
+				defer resp.Body.Close()
+				var statuses map[string]concurrencyStatus
+				if err := json.NewDecoder(resp.Body).Decode(&statuses); err != nil {
+					t.Fatalf("decoding concurrency status: %v", err)
+				}
+				return statuses["fn"]
+			}
+
+			first := make(chan int)
+			go func() {
+				resp, err := http.Get(srv.URL + "/fn")
+				if err != nil {
+					first <- 0
+					return
+				}
+				resp.Body.Close()
+				first <- resp.StatusCode
+			}()
+			<-started
+			if got := status(); got.InFlight != 1 || got.MaxConcurrency != 1 {
+				t.Errorf("concurrency status = %+v, want 1 in flight out of 1", got)
+			}
+
+			second := make(chan *http.Response)
+			go func() {
+				resp, err := http.Get(srv.URL + "/fn")
+				if err != nil {
+					second <- nil
+					return
+				}
+				resp.Body.Close()
+				second <- resp
+			}()
+
+			if tc.wantQueued > 0 {
+				deadline := time.Now().Add(5 * time.Second)
+				for status().Queued != tc.wantQueued && time.Now().Before(deadline) {
+					time.Sleep(10 * time.Millisecond)
+				}
+				if got := status().Queued; got != tc.wantQueued {
+					t.Errorf("queued invocations = %d, want %d", got, tc.wantQueued)
+				}
+			}
+			if tc.release {
+				close(release)
+			}
+
+			resp := <-second
+			if !tc.release {
+				close(release)
+			}
+			if resp == nil {
+				t.Fatalf("second request failed")
+			}
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("second response status = %v, want %v", resp.StatusCode, tc.wantStatus)
+			}
+			if tc.wantStatus != http.StatusOK && resp.Header.Get("Retry-After") == "" {
+				t.Errorf("second response has no Retry-After header")
+			}
+			if got := <-first; got != http.StatusOK {
+				t.Errorf("first response status = %v, want %v", got, http.StatusOK)
+			}
+			if got := status(); got.InFlight != 0 || got.Queued != 0 {
+				t.Errorf("concurrency status after all invocations = %+v, want none in flight or queued", got)
+			}
+		})
+	}
+}
+
+func TestConcurrencyStatusOptIn(t *testing.T) {
+	tcs := []struct {
+		name     string
+		enable   bool
+		target   bool
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "not enabled",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "not enabled with a target function",
+			target:   true,
+			wantCode: http.StatusOK,
+			wantBody: "function",
+		},
+		{
+			name:     "enabled",
+			enable:   true,
+			wantCode: http.StatusOK,
+			wantBody: `{"fn":{"inFlight":0,"queued":0,"maxConcurrency":1,"maxQueueLength":0}}`,
+		},
+		{
+			name:     "enabled with a target function",
+			enable:   true,
+			target:   true,
+			wantCode: http.StatusOK,
+			wantBody: `{"fn":{"inFlight":0,"queued":0,"maxConcurrency":1,"maxQueueLength":0}}`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer cleanup()
+			defer func() { concurrencyStatusPath = "" }()
+
+			if tc.enable {
+				if err := EnableConcurrencyStatus(testConcurrencyStatusPath); err != nil {
+					t.Fatalf("EnableConcurrencyStatus(): %v", err)
+				}
+			}
+			functions.HTTP("fn", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("function"))
+			}, functions.WithMaxConcurrency(1))
+			cfg := &Config{}
+			if tc.target {
+				cfg.Target = "fn"
+			}
+			server, err := initServerWithConfig(cfg)
+			if err != nil {
+				t.Fatalf("initServerWithConfig(): %v", err)
+			}
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, httptest.NewRequest("GET", testConcurrencyStatusPath, nil))
+			if rec.Code != tc.wantCode {
+				t.Errorf("%s status = %v, want %v", testConcurrencyStatusPath, rec.Code, tc.wantCode)
+			}
+			if tc.wantBody != "" {
+				if got := strings.TrimSpace(rec.Body.String()); got != tc.wantBody {
+					t.Errorf("%s body = %q, want %q", testConcurrencyStatusPath, got, tc.wantBody)
+				}
+			}
+		})
+	}
+}
+
+func TestEnableConcurrencyStatusInvalidPath(t *testing.T) {
+	defer func() { concurrencyStatusPath = "" }()
+
+	for _, path := range []string{"", "/", "_ff/concurrency", "/_ff/concurrency/"} {
+		if err := EnableConcurrencyStatus(path); err == nil {
+			t.Errorf("EnableConcurrencyStatus(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestConcurrencyLimitWithTimeoutResponse(t *testing.T) {
+	defer cleanup()
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	release := make(chan struct{})
+	functions.HTTP("fn", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+		<-release
+	}, functions.WithMaxConcurrency(1), functions.WithTimeout(50*time.Millisecond), functions.WithTimeoutResponse())
+	server, err := initServer()
+	if err != nil {
+		t.Fatalf("initServer(): %v", err)
+	}
+
+	serve := func() int {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest("GET", "/fn", nil))
+		return rec.Code
+	}
+	// The first invocation times out but keeps running, holding on to the
+	// only slot.
+	if got := serve(); got != http.StatusGatewayTimeout {
+		t.Errorf("first response status = %v, want %v", got, http.StatusGatewayTimeout)
+	}
+	for i := 0; i < 2; i++ {
+		if got := serve(); got != http.StatusTooManyRequests {
+			t.Errorf("response status while the timed out invocation runs = %v, want %v", got, http.StatusTooManyRequests)
+		}
+	}
+	mu.Lock()
+	if maxRunning != 1 {
+		t.Errorf("%d invocations ran at once, want 1", maxRunning)
+	}
+	mu.Unlock()
+
+	// The slot is released once the timed out invocation returns.
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	got := serve()
+	for got == http.StatusTooManyRequests && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		got = serve()
+	}
+	if got != http.StatusOK {
+		t.Errorf("response status after the timed out invocation returned = %v, want %v", got, http.StatusOK)
+	}
+}
+
+func TestConcurrencyStatusPathCollision(t *testing.T) {
+	for _, path := range []string{testConcurrencyStatusPath, testConcurrencyStatusPath + "/fn"} {
+		t.Run(path, func(t *testing.T) {
+			defer cleanup()
+			defer func() { concurrencyStatusPath = "" }()
+
+			if err := EnableConcurrencyStatus(testConcurrencyStatusPath); err != nil {
+				t.Fatalf("EnableConcurrencyStatus(): %v", err)
+			}
+			functions.HTTP("fn", func(w http.ResponseWriter, r *http.Request) {}, functions.WithMaxConcurrency(1))
+			if err := RegisterHTTPFunctionContext(context.Background(), path, func(w http.ResponseWriter, r *http.Request) {}); err != nil {
+				t.Fatalf("RegisterHTTPFunctionContext(): %v", err)
+			}
+			if _, err := initServer(); err == nil || !strings.Contains(err.Error(), "collides") {
+				t.Errorf("initServer() error = %v, want a path collision error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/internal/netutil"
+)
+
+const defaultPort = "8080"
+
+// activeConfig is the configuration of the last server created by
+// initServerWithConfig.
+var activeConfig atomic.Pointer[Config]
+
+// Config is the configuration of the framework. It is usually loaded once at
+// startup with LoadConfig and passed to StartWithConfig, so that the behavior
+// of the server does not depend on environment variables changing later.
+type Config struct {
+	// Target is the name of the function to serve at "/". If empty, all the
+	// registered functions are served at their paths. Set by FUNCTION_TARGET
+	// or --target.
+	Target string
+	// Port is the port to listen on, or "unix://" followed by the path of a
+	// Unix domain socket. Defaults to "8080". Set by PORT or --port.
+	Port string
+	// SignatureType is the signature type of the function to serve: "http",
+	// "event", "cloudevent" or "typed". If empty, it is inferred from how the
+	// function was registered. Set by FUNCTION_SIGNATURE_TYPE or
+	// --signature-type.
+	SignatureType string
+	// Source is the directory containing the source of the function, for
+	// tools building it. Set by FUNCTION_SOURCE or --source.
+	Source string
+	// Debug logs every invocation, as WithAccessLog does. Set by
+	// FUNCTION_DEBUG or --debug.
+	Debug bool
+
+	// Service and Revision are the names of the service and revision the
+	// function is deployed as, set by K_SERVICE and K_REVISION when running
+	// on GCF. Logs and error reports are structured when Service is set.
+	Service  string
+	Revision string
+	// Timeout is the maximum duration of an invocation, set by
+	// CLOUD_RUN_TIMEOUT_SECONDS. Zero means no timeout.
+	Timeout time.Duration
+}
+
+// LoadConfig returns the configuration set by environment variables,
+// overridden by the flags in args, usually os.Args[1:]. It returns an error
+// if a value is invalid or args contains an unknown flag.
+func LoadConfig(args []string) (*Config, error) {
+	cfg, err := configFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	fs := flag.NewFlagSet("funcframework", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// RegisterFlags defines the --target, --port, --signature-type, --source and
+// --debug flags on fs, setting the fields of c. The current values of the
+// fields are used as defaults. This lets programs with flags of their own
+// parse them along with the framework's.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Target, "target", c.Target, "name of the function to serve at \"/\" (FUNCTION_TARGET)")
+	fs.StringVar(&c.Port, "port", c.Port, "port or unix:// socket path to listen on (PORT)")
+	fs.StringVar(&c.SignatureType, "signature-type", c.SignatureType, "signature type of the function: http, event, cloudevent or typed (FUNCTION_SIGNATURE_TYPE)")
+	fs.StringVar(&c.Source, "source", c.Source, "directory containing the source of the function (FUNCTION_SOURCE)")
+	fs.BoolVar(&c.Debug, "debug", c.Debug, "log every invocation (FUNCTION_DEBUG)")
+}
+
+// Validate returns an error describing the invalid values of c, if any.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Port != "" {
+		if path, ok := strings.CutPrefix(c.Port, netutil.UnixSocketPrefix); ok {
+			if path == "" {
+				errs = append(errs, fmt.Errorf("invalid port %q: missing socket path", c.Port))
+			}
+		} else if n, err := strconv.Atoi(c.Port); err != nil || n < 0 || n > 65535 {
+			errs = append(errs, fmt.Errorf("invalid port %q: must be a number between 0 and 65535 or a unix:// socket path", c.Port))
+		}
+	}
+	switch c.SignatureType {
+	case "", httpSignatureType, eventSignatureType, cloudEventSignatureType, typedSignatureType:
+	default:
+		errs = append(errs, fmt.Errorf("invalid signature type %q: must be one of %q, %q, %q or %q", c.SignatureType, httpSignatureType, eventSignatureType, cloudEventSignatureType, typedSignatureType))
+	}
+	if c.Source != "" {
+		if fi, err := os.Stat(c.Source); err != nil || !fi.IsDir() {
+			errs = append(errs, fmt.Errorf("invalid source %q: not a directory", c.Source))
+		}
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("invalid timeout %v: must not be negative", c.Timeout))
+	}
+	return errors.Join(errs...)
+}
+
+// configFromEnv returns the configuration set by environment variables. If a
+// variable has an invalid value, the field is left unset and an error is
+// returned along with the rest of the configuration.
+func configFromEnv() (*Config, error) {
+	cfg := &Config{
+		Target:        os.Getenv("FUNCTION_TARGET"),
+		Port:          os.Getenv("PORT"),
+		SignatureType: os.Getenv("FUNCTION_SIGNATURE_TYPE"),
+		Source:        os.Getenv("FUNCTION_SOURCE"),
+		Service:       os.Getenv("K_SERVICE"),
+		Revision:      os.Getenv("K_REVISION"),
+	}
+	if cfg.Port == "" {
+		cfg.Port = defaultPort
+	}
+
+	var errs []error
+	if s := os.Getenv("FUNCTION_DEBUG"); s != "" {
+		debug, err := strconv.ParseBool(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not parse FUNCTION_DEBUG as a boolean: %v", err))
+		}
+		cfg.Debug = debug
+	}
+	if s := os.Getenv("CLOUD_RUN_TIMEOUT_SECONDS"); s != "" {
+		secs, err := strconv.Atoi(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not parse CLOUD_RUN_TIMEOUT_SECONDS as an integer value in seconds: %v", err))
+		}
+		cfg.Timeout = time.Duration(secs) * time.Second
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// currentConfig returns the configuration of the server, or the one set by
+// environment variables if no server was created.
+func currentConfig() *Config {
+	if cfg := activeConfig.Load(); cfg != nil {
+		return cfg
+	}
+	cfg, _ := configFromEnv()
+	return cfg
+}
+
+// onGCF reports whether the function is running on GCF, where logs are
+// structured.
+func (c *Config) onGCF() bool {
+	return c.Service != ""
+}
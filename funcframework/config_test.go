@@ -0,0 +1,163 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	tcs := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		want    *Config
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			want: &Config{Port: "8080"},
+		},
+		{
+			name: "environment",
+			env: map[string]string{
+				"FUNCTION_TARGET":           "HelloWorld",
+				"PORT":                      "9090",
+				"FUNCTION_SIGNATURE_TYPE":   "cloudevent",
+				"FUNCTION_SOURCE":           dir,
+				"FUNCTION_DEBUG":            "true",
+				"K_SERVICE":                 "hello",
+				"K_REVISION":                "hello-00001",
+				"CLOUD_RUN_TIMEOUT_SECONDS": "60",
+			},
+			want: &Config{
+				Target:        "HelloWorld",
+				Port:          "9090",
+				SignatureType: "cloudevent",
+				Source:        dir,
+				Debug:         true,
+				Service:       "hello",
+				Revision:      "hello-00001",
+				Timeout:       time.Minute,
+			},
+		},
+		{
+			name: "flags override environment",
+			env: map[string]string{
+				"FUNCTION_TARGET": "HelloWorld",
+				"PORT":            "9090",
+			},
+			args: []string{"--target", "Goodbye", "--port=unix:///tmp/fn.sock", "--signature-type", "typed", "--source", dir, "--debug"},
+			want: &Config{
+				Target:        "Goodbye",
+				Port:          "unix:///tmp/fn.sock",
+				SignatureType: "typed",
+				Source:        dir,
+				Debug:         true,
+			},
+		},
+		{
+			name:    "invalid timeout",
+			env:     map[string]string{"CLOUD_RUN_TIMEOUT_SECONDS": "aaa"},
+			wantErr: "CLOUD_RUN_TIMEOUT_SECONDS",
+		},
+		{
+			name:    "invalid port",
+			args:    []string{"--port", "http"},
+			wantErr: `invalid port "http"`,
+		},
+		{
+			name:    "invalid signature type",
+			env:     map[string]string{"FUNCTION_SIGNATURE_TYPE": "pubsub"},
+			wantErr: `invalid signature type "pubsub"`,
+		},
+		{
+			name:    "missing source",
+			args:    []string{"--source", dir + "/missing"},
+			wantErr: "invalid source",
+		},
+		{
+			name:    "unknown flag",
+			args:    []string{"--verbose"},
+			wantErr: "flag provided but not defined",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, k := range []string{"FUNCTION_TARGET", "PORT", "FUNCTION_SIGNATURE_TYPE", "FUNCTION_SOURCE", "FUNCTION_DEBUG", "K_SERVICE", "K_REVISION", "CLOUD_RUN_TIMEOUT_SECONDS"} {
+				t.Setenv(k, tc.env[k])
+			}
+
+			got, err := LoadConfig(tc.args)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("LoadConfig() error = %v, want it to contain %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig(): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("LoadConfig() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInitServerWithConfig(t *testing.T) {
+	defer cleanup()
+	// The server is configured by the Config passed in, not by the
+	// environment.
+	t.Setenv("FUNCTION_TARGET", "")
+	t.Setenv("K_SERVICE", "")
+
+	functions.HTTP("HelloWorld", func(w http.ResponseWriter, r *http.Request) {
+		panic("intentional panic for test")
+	})
+	server, err := initServerWithConfig(&Config{Target: "HelloWorld", Service: "hello"})
+	if err != nil {
+		t.Fatalf("initServerWithConfig(): %v", err)
+	}
+
+	origStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	defer func() { os.Stderr = origStderr }()
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	w.Close()
+	stderr, _ := io.ReadAll(r)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("response status = %v, want %v", rec.Code, http.StatusInternalServerError)
+	}
+	if want := `"serviceContext":{"service":"hello"}`; !strings.Contains(string(stderr), want) {
+		t.Errorf("stderr = %q, want it to contain %q", stderr, want)
+	}
+}
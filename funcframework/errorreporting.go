@@ -0,0 +1,203 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/internal/registry"
+)
+
+const reportedErrorEventType = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+var functionInfoContextKey contextKey = "functionInfo"
+
+// functionInfo describes the function serving an invocation in error reports.
+type functionInfo struct {
+	name     string
+	location *reportLocation
+}
+
+func newFunctionInfo(fn *registry.RegisteredFunction) *functionInfo {
+	fi := &functionInfo{name: functionName(fn)}
+	var userFn interface{}
+	switch {
+	case fn.HTTPFn != nil:
+		userFn = fn.HTTPFn
+	case fn.CloudEventFn != nil:
+		userFn = fn.CloudEventFn
+	case fn.CloudEventResponseFn != nil:
+		userFn = fn.CloudEventResponseFn
+	case fn.EventFn != nil:
+		userFn = fn.EventFn
+	case fn.TypedFn != nil:
+		userFn = fn.TypedFn
+	default:
+		return fi
+	}
+	if f := runtime.FuncForPC(reflect.ValueOf(userFn).Pointer()); f != nil {
+		file, line := f.FileLine(f.Entry())
+		fi.location = &reportLocation{
+			FilePath:     file,
+			LineNumber:   line,
+			FunctionName: f.Name(),
+		}
+	}
+	return fi
+}
+
+// withFunctionInfo wraps h, the handler serving fn, so that the context of
+// every invocation describes fn for error reports.
+func withFunctionInfo(fn *registry.RegisteredFunction, h http.Handler) http.Handler {
+	fi := newFunctionInfo(fn)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), functionInfoContextKey, fi)))
+	})
+}
+
+func functionInfoFromContext(ctx context.Context) *functionInfo {
+	if ctx == nil {
+		return nil
+	}
+	val := ctx.Value(functionInfoContextKey)
+	if val == nil {
+		return nil
+	}
+	return val.(*functionInfo)
+}
+
+// reportedErrorEvent is a structured log event recognized by Cloud Error
+// Reporting. See
+// https://cloud.google.com/error-reporting/docs/formatting-error-messages.
+type reportedErrorEvent struct {
+	Type           string            `json:"@type"`
+	Severity       LogSeverity       `json:"severity"`
+	Message        string            `json:"message"`
+	ServiceContext serviceContext    `json:"serviceContext"`
+	Context        *errorContext     `json:"context,omitempty"`
+	Trace          string            `json:"logging.googleapis.com/trace,omitempty"`
+	SpanID         string            `json:"logging.googleapis.com/spanId,omitempty"`
+	TraceSampled   bool              `json:"logging.googleapis.com/trace_sampled,omitempty"`
+	Labels         map[string]string `json:"logging.googleapis.com/labels,omitempty"`
+}
+
+type serviceContext struct {
+	Service string `json:"service"`
+	Version string `json:"version,omitempty"`
+}
+
+type errorContext struct {
+	HTTPRequest    *httpRequestContext `json:"httpRequest,omitempty"`
+	ReportLocation *reportLocation     `json:"reportLocation,omitempty"`
+}
+
+type httpRequestContext struct {
+	Method    string `json:"method,omitempty"`
+	URL       string `json:"url,omitempty"`
+	UserAgent string `json:"userAgent,omitempty"`
+	Referrer  string `json:"referrer,omitempty"`
+	RemoteIP  string `json:"remoteIp,omitempty"`
+}
+
+type reportLocation struct {
+	FilePath     string `json:"filePath"`
+	LineNumber   int    `json:"lineNumber"`
+	FunctionName string `json:"functionName"`
+}
+
+// reportPanic logs r, recovered from a panic, along with stack, the stack
+// trace of the panicking goroutine. When running on GCF the panic is logged in
+// the Error Reporting format, otherwise it is logged as text prefixed with
+// genericMsg.
+func reportPanic(ctx context.Context, genericMsg string, r interface{}, stack []byte) {
+	if !currentConfig().onGCF() {
+		logFrameworkError(ctx, fmt.Sprintf("%s\npanic message: %v\nstack trace: %v\n%s", genericMsg, r, r, stack))
+		return
+	}
+	// Error Reporting parses Go stack traces in the format printed by the
+	// runtime for unrecovered panics.
+	writeReportedErrorEvent(ctx, fmt.Sprintf("panic: %v\n\n%s", r, stack))
+}
+
+// reportFunctionError logs err, returned by the user function. When running
+// on GCF the error is logged in the Error Reporting format, attributed to the
+// user function, otherwise it is logged as text.
+func reportFunctionError(ctx context.Context, err interface{}) {
+	if !currentConfig().onGCF() {
+		logFrameworkError(ctx, fmtFunctionError(err))
+		return
+	}
+	writeReportedErrorEvent(ctx, strings.TrimSuffix(fmtFunctionError(err), "\n"))
+}
+
+// writeReportedErrorEvent writes msg to stderr as a ReportedErrorEvent
+// carrying the service, function, request and logging IDs of ctx.
+func writeReportedErrorEvent(ctx context.Context, msg string) {
+	cfg := currentConfig()
+	event := reportedErrorEvent{
+		Type:     reportedErrorEventType,
+		Severity: SeverityError,
+		Message:  msg,
+		ServiceContext: serviceContext{
+			Service: cfg.Service,
+			Version: cfg.Revision,
+		},
+	}
+
+	if ctx != nil {
+		var errCtx errorContext
+		if req := httpRequestFromContext(ctx); req != nil {
+			errCtx.HTTPRequest = &httpRequestContext{
+				Method:    req.RequestMethod,
+				URL:       req.RequestURL,
+				UserAgent: req.UserAgent,
+				Referrer:  req.Referer,
+				RemoteIP:  req.RemoteIP,
+			}
+		}
+		labels := map[string]string{}
+		if fi := functionInfoFromContext(ctx); fi != nil {
+			errCtx.ReportLocation = fi.location
+			labels["function_name"] = fi.name
+		}
+		if errCtx != (errorContext{}) {
+			event.Context = &errCtx
+		}
+		if ids := loggingIDsFromContext(ctx); ids != nil {
+			event.Trace = ids.trace
+			event.SpanID = ids.spanID
+			event.TraceSampled = ids.traceSampled
+			if ids.executionID != "" {
+				labels["execution_id"] = ids.executionID
+			}
+		}
+		if len(labels) > 0 {
+			event.Labels = labels
+		}
+	}
+
+	marshalled, err := json.Marshal(event)
+	if err != nil {
+		logFrameworkError(ctx, msg)
+		return
+	}
+	fmt.Fprintln(logOutput(ctx), string(marshalled))
+}
@@ -0,0 +1,188 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cecontext "github.com/cloudevents/sdk-go/v2/context"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestErrorReporting(t *testing.T) {
+	tcs := []struct {
+		name        string
+		register    func()
+		body        string
+		contentType string
+		wantMessage string
+		wantStatus  int
+	}{
+		{
+			name: "http panic",
+			register: func() {
+				functions.HTTP("fn", func(w http.ResponseWriter, r *http.Request) {
+					panic("intentional panic for test")
+				})
+			},
+			wantMessage: "panic: intentional panic for test\n\ngoroutine ",
+			wantStatus:  http.StatusInternalServerError,
+		},
+		{
+			name: "event function error",
+			register: func() {
+				if err := RegisterEventFunctionContext(context.Background(), "/fn", func(ctx context.Context, data map[string]interface{}) error {
+					return fmt.Errorf("error for test")
+				}); err != nil {
+					t.Fatalf("RegisterEventFunctionContext(): %v", err)
+				}
+			},
+			body:        `{}`,
+			wantMessage: fmt.Sprintf(fnErrorMessageStderrTmpl, "error for test"),
+			wantStatus:  http.StatusInternalServerError,
+		},
+		{
+			// The panic must not reach the CloudEvents SDK, which would log
+			// it again.
+			name: "cloudevent panic",
+			register: func() {
+				if err := RegisterCloudEventFunctionContext(context.Background(), "/fn", func(ctx context.Context, e cloudevents.Event) error {
+					panic("intentional panic for test")
+				}); err != nil {
+					t.Fatalf("RegisterCloudEventFunctionContext(): %v", err)
+				}
+			},
+			body:        `{"specversion":"1.0","type":"test","source":"test","id":"1","data":{}}`,
+			contentType: cloudevents.ApplicationCloudEventsJSON,
+			wantMessage: "panic: intentional panic for test\n\ngoroutine ",
+			wantStatus:  http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer cleanup()
+			t.Setenv("K_SERVICE", "test-service")
+			t.Setenv("K_REVISION", "test-service-00001")
+
+			tc.register()
+			server, err := initServer()
+			if err != nil {
+				t.Fatalf("initServer(): %v", err)
+			}
+
+			origStderr := os.Stderr
+			r, w, _ := os.Pipe()
+			os.Stderr = w
+			defer func() { os.Stderr = origStderr }()
+
+			req := httptest.NewRequest("POST", "/fn", bytes.NewBufferString(tc.body))
+			// The CloudEvents SDK logs to the logger in the request context,
+			// which also goes to stderr.
+			logger := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(w), zap.DebugLevel))
+			req = req.WithContext(cecontext.WithLogger(req.Context(), logger.Sugar()))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			req.Header.Set("Function-Execution-Id", "exec")
+			req.Header.Set("X-Cloud-Trace-Context", "0123456789abcdef/1;o=1")
+			req.Header.Set("User-Agent", "test-agent")
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+
+			w.Close()
+			stderr, _ := io.ReadAll(r)
+			os.Stderr = origStderr
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("response status = %v, want %v", rec.Code, tc.wantStatus)
+			}
+
+			// The error is logged once, as the reported error event.
+			var entries []string
+			for _, line := range strings.Split(string(stderr), "\n") {
+				if strings.TrimSpace(line) != "" {
+					entries = append(entries, line)
+				}
+			}
+			if len(entries) != 1 {
+				t.Errorf("stderr has %d log entries, want 1: %q", len(entries), stderr)
+			}
+
+			var event *reportedErrorEvent
+			for _, line := range entries {
+				var e reportedErrorEvent
+				if json.Unmarshal([]byte(line), &e) == nil && e.Type == reportedErrorEventType {
+					event = &e
+					break
+				}
+			}
+			if event == nil {
+				t.Fatalf("stderr %q has no reported error event", stderr)
+			}
+
+			if !strings.HasPrefix(event.Message, tc.wantMessage) {
+				t.Errorf("reported error message = %q, want prefix %q", event.Message, tc.wantMessage)
+			}
+			if event.Severity != SeverityError {
+				t.Errorf("reported error severity = %q, want %q", event.Severity, SeverityError)
+			}
+			if want := (serviceContext{Service: "test-service", Version: "test-service-00001"}); event.ServiceContext != want {
+				t.Errorf("reported error service context = %+v, want %+v", event.ServiceContext, want)
+			}
+			if event.Context == nil || event.Context.HTTPRequest == nil || event.Context.ReportLocation == nil {
+				t.Fatalf("reported error context = %+v, want request and report location", event.Context)
+			}
+			if got := *event.Context.HTTPRequest; got.Method != "POST" || got.URL != "/fn" || got.UserAgent != "test-agent" {
+				t.Errorf("reported error request = %+v, want POST /fn from test-agent", got)
+			}
+			if got := event.Context.ReportLocation; !strings.Contains(got.FunctionName, "TestErrorReporting") || !strings.HasSuffix(got.FilePath, "errorreporting_test.go") {
+				t.Errorf("reported error location = %+v, want the registered function", got)
+			}
+			if event.Trace != "0123456789abcdef" || event.Labels["execution_id"] != "exec" || !strings.HasSuffix(event.Labels["function_name"], "fn") {
+				t.Errorf("reported error trace = %q and labels = %v, want trace, execution ID and function name", event.Trace, event.Labels)
+			}
+		})
+	}
+}
+
+func TestErrorReportingLocal(t *testing.T) {
+	t.Setenv("K_SERVICE", "")
+
+	origStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	defer func() { os.Stderr = origStderr }()
+
+	reportFunctionError(context.Background(), fmt.Errorf("error for test"))
+
+	w.Close()
+	stderr, _ := io.ReadAll(r)
+	if want := fmtFunctionError("error for test"); string(stderr) != want {
+		t.Errorf("stderr = %q, want %q", stderr, want)
+	}
+}
@@ -3,10 +3,13 @@ package funcframework
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"os"
 	"reflect"
 	"regexp"
 	"strings"
@@ -14,6 +17,7 @@ import (
 	"cloud.google.com/go/functions/metadata"
 	"github.com/GoogleCloudPlatform/functions-framework-go/internal/events/pubsub"
 	"github.com/GoogleCloudPlatform/functions-framework-go/internal/fftypes"
+	"github.com/cloudevents/sdk-go/v2/binding"
 )
 
 const (
@@ -21,8 +25,9 @@ const (
 	contentTypeHeader   = "Content-Type"
 	contentLengthHeader = "Content-Length"
 
-	ceSpecVersion   = "1.0"
-	jsonContentType = "application/cloudevents+json"
+	ceSpecVersion    = "1.0"
+	jsonContentType  = "application/cloudevents+json"
+	batchContentType = "application/cloudevents-batch+json"
 
 	firebaseAuthCEService = "firebaseauth.googleapis.com"
 	firebaseCEService     = "firebase.googleapis.com"
@@ -158,10 +163,11 @@ func getBackgroundEvent(body []byte, path string) (*metadata.Metadata, interface
 func runBackgroundEvent(w http.ResponseWriter, r *http.Request, m *metadata.Metadata, data, fn interface{}) {
 	b, err := encodeData(data)
 	if err != nil {
-		writeHTTPErrorResponse(w, http.StatusBadRequest, crashStatus, fmt.Sprintf("Unable to encode data %v: %s", data, err.Error()))
+		writeHTTPErrorResponse(r.Context(), w, http.StatusBadRequest, crashStatus, fmt.Sprintf("Unable to encode data %v: %s", data, err.Error()))
 		return
 	}
 	ctx := metadata.NewContext(r.Context(), m)
+	setEventSpanAttributes(ctx, m.EventType, m.EventID)
 	runUserFunctionWithContext(ctx, w, r, b, fn)
 }
 
@@ -185,21 +191,38 @@ func validateEventFunction(fn interface{}) error {
 
 func convertBackgroundToCloudEvent(ceHandler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if currentConfig().onGCF() {
+			// Force flush of logs after every function trigger when running on GCF.
+			defer fmt.Println()
+			defer fmt.Fprintln(os.Stderr)
+		}
+		r, cancel := setupRequestContext(w, r)
+		if cancel != nil {
+			defer cancel()
+		}
 		// If the incoming request is not CloudEvent, make it so.
 		if r.Header.Get(ceIDHeader) == "" && !strings.Contains(r.Header.Get(contentTypeHeader), "cloudevents") {
 			if err := convertBackgroundToCloudEventRequest(r); err != nil {
-				writeHTTPErrorResponse(w, http.StatusBadRequest, crashStatus, fmt.Sprintf("%v", err))
+				writeHTTPErrorResponse(r.Context(), w, http.StatusBadRequest, crashStatus, fmt.Sprintf("%v", err))
 				return
 			}
 		}
-		r, cancel := setContextTimeoutIfRequested(r)
-		if cancel != nil {
-			defer cancel()
-		}
+		r = setResponseEventEncoding(r)
 		ceHandler.ServeHTTP(w, r)
 	})
 }
 
+// setResponseEventEncoding makes any response event returned by the user
+// function use the same content mode as the incoming request: structured if the
+// request was a structured CloudEvent, binary otherwise.
+func setResponseEventEncoding(r *http.Request) *http.Request {
+	if !strings.HasPrefix(r.Header.Get(contentTypeHeader), jsonContentType) {
+		return r
+	}
+	ctx := binding.WithPreferredEventEncoding(r.Context(), binding.EncodingStructured)
+	return r.WithContext(ctx)
+}
+
 func encodeData(d interface{}) ([]byte, error) {
 	var buf bytes.Buffer
 	enc := json.NewEncoder(&buf)
@@ -285,26 +308,62 @@ func firebaseAuthSubject(data interface{}) (string, error) {
 	return fmt.Sprintf("users/%v", d["uid"]), nil
 }
 
+// backgroundEventTimestamp returns the timestamp of the background event in
+// body as it is formatted there, or "" if it has none.
+func backgroundEventTimestamp(body []byte) string {
+	var e struct {
+		Timestamp string `json:"timestamp"`
+		Context   struct {
+			Timestamp string `json:"timestamp"`
+		} `json:"context"`
+		// Message is set for legacy Pub/Sub push subscription events.
+		Message struct {
+			PublishTime string `json:"publishTime"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(body, &e); err != nil {
+		return ""
+	}
+	for _, ts := range []string{e.Context.Timestamp, e.Timestamp, e.Message.PublishTime} {
+		if ts != "" {
+			return ts
+		}
+	}
+	return ""
+}
+
 func convertBackgroundToCloudEventRequest(r *http.Request) error {
 	body, err := readHTTPRequestBody(r)
 	if err != nil {
 		return err
 	}
 
-	md, d, err := getBackgroundEvent(body, r.URL.Path)
+	encoded, err := convertBackgroundEventToCloudEvent(body, r.URL.Path)
 	if err != nil {
-		return fmt.Errorf("parsing background event body %s: %v", string(body), err)
+		return err
 	}
 
-	if md == nil || d == nil {
-		return fmt.Errorf("unable to extract background event from %s", string(body))
+	r.Header.Set(contentTypeHeader, jsonContentType)
+	r.Body = ioutil.NopCloser(bytes.NewReader(encoded))
+	r.Header.Set(contentLengthHeader, fmt.Sprint(len(encoded)))
+	return nil
+}
+
+// convertBackgroundEventToCloudEvent returns the JSON encoded structured mode
+// CloudEvent equivalent to the background event in body, received at path.
+func convertBackgroundEventToCloudEvent(body []byte, path string) ([]byte, error) {
+	md, d, err := getBackgroundEvent(body, path)
+	if err != nil {
+		return nil, fmt.Errorf("parsing background event body %s: %v", string(body), err)
 	}
 
-	r.Header.Set(contentTypeHeader, jsonContentType)
+	if md == nil || d == nil {
+		return nil, fmt.Errorf("unable to extract background event from %s", string(body))
+	}
 
 	t, ok := typeBackgroundToCloudEvent[md.EventType]
 	if !ok {
-		return fmt.Errorf("unable to find CloudEvent equivalent event type for %s", md.EventType)
+		return nil, fmt.Errorf("unable to find CloudEvent equivalent event type for %s", md.EventType)
 	}
 
 	service := md.Resource.Service
@@ -316,7 +375,7 @@ func convertBackgroundToCloudEventRequest(r *http.Request) error {
 		}
 		// If service is still empty, we didn't find a match in the map. Return the error.
 		if service == "" {
-			return fmt.Errorf("unable to find CloudEvent equivalent service for %s", md.EventType)
+			return nil, fmt.Errorf("unable to find CloudEvent equivalent service for %s", md.EventType)
 		}
 	}
 
@@ -328,7 +387,7 @@ func convertBackgroundToCloudEventRequest(r *http.Request) error {
 	var subject string
 	resource, subject, err = splitResource(service, resource)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	ce := map[string]interface{}{
@@ -349,10 +408,15 @@ func convertBackgroundToCloudEventRequest(r *http.Request) error {
 	case pubSubCEService:
 		data, ok := d.(map[string]interface{})
 		if !ok {
-			return fmt.Errorf(`invalid "data" field in event payload, "data": %q`, d)
+			return nil, fmt.Errorf(`invalid "data" field in event payload, "data": %q`, d)
 		}
 
-		data["publishTime"] = md.Timestamp
+		// The publish time is passed on as sent, with the same precision.
+		if ts := backgroundEventTimestamp(body); ts != "" {
+			data["publishTime"] = ts
+		} else {
+			data["publishTime"] = md.Timestamp
+		}
 		data["messageId"] = md.EventID
 
 		// In a Pub/Sub CloudEvent "data" is wrapped by "message".
@@ -372,14 +436,14 @@ func convertBackgroundToCloudEventRequest(r *http.Request) error {
 			Domain string `json:"domain"`
 		}
 		if err := json.Unmarshal(body, &dbDomain); err != nil {
-			return fmt.Errorf("unable to unmarshal %q domain from event payload %q: %v", firebaseDBCEService, string(body), err)
+			return nil, fmt.Errorf("unable to unmarshal %q domain from event payload %q: %v", firebaseDBCEService, string(body), err)
 		}
 
 		location := "us-central1"
 		if dbDomain.Domain != "firebaseio.com" {
 			domainSplit := strings.SplitN(dbDomain.Domain, ".", 2)
 			if len(domainSplit) != 2 {
-				return fmt.Errorf("invalid %q domain: %q", firebaseDBCEService, dbDomain.Domain)
+				return nil, fmt.Errorf("invalid %q domain: %q", firebaseDBCEService, dbDomain.Domain)
 			}
 			location = domainSplit[0]
 		}
@@ -389,50 +453,264 @@ func convertBackgroundToCloudEventRequest(r *http.Request) error {
 
 	encoded, err := json.Marshal(ce)
 	if err != nil {
-		return fmt.Errorf("unable to marshal CloudEvent %v: %v", ce, err)
+		return nil, fmt.Errorf("unable to marshal CloudEvent %v: %v", ce, err)
 	}
+	return encoded, nil
+}
 
-	r.Body = ioutil.NopCloser(bytes.NewReader(encoded))
-	r.Header.Set(contentLengthHeader, fmt.Sprint(len(encoded)))
-	return nil
+// cloudEventContext holds the attributes and data of an incoming CloudEvent
+// that are needed to build the equivalent background event.
+type cloudEventContext struct {
+	Type       string
+	Source     string
+	Subject    string
+	Id         string
+	Time       string
+	Data       []byte
+	Extensions map[string]string
+}
+
+// cloudEventAttributes are the CloudEvent context attributes and data members
+// defined by the CloudEvents spec. Any other attribute is an extension.
+var cloudEventAttributes = map[string]bool{
+	"specversion":     true,
+	"type":            true,
+	"source":          true,
+	"subject":         true,
+	"id":              true,
+	"time":            true,
+	"datacontenttype": true,
+	"dataschema":      true,
+	"data":            true,
+	"data_base64":     true,
+}
+
+// CloudEventExtensionsFromContext returns the extension attributes, such as
+// "traceparent" or "knativearrivaltime", of the CloudEvent that was converted
+// into the background event being handled. Values are in their canonical
+// string form. It returns nil if the event was not delivered as a CloudEvent
+// or had no extension attributes.
+func CloudEventExtensionsFromContext(ctx context.Context) map[string]string {
+	val := ctx.Value(cloudEventExtensionsContextKey)
+	if val == nil {
+		return nil
+	}
+	return val.(map[string]string)
+}
+
+// contextWithCloudEventExtensions adds the extensions of ce to ctx. If ce has a
+// "traceparent" extension, it replaces the trace and span IDs used for logging.
+func contextWithCloudEventExtensions(ctx context.Context, ce cloudEventContext) context.Context {
+	if len(ce.Extensions) == 0 {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, cloudEventExtensionsContextKey, ce.Extensions)
+	if tp, ok := ce.Extensions["traceparent"]; ok {
+		ctx = contextWithTraceparent(ctx, tp)
+	}
+	return ctx
 }
 
+// shouldConvertCloudEventToBackgroundRequest reports whether r carries one or
+// more CloudEvents, in binary, structured or batch content mode.
 func shouldConvertCloudEventToBackgroundRequest(r *http.Request) bool {
-	_, ok := typeCloudToBackgroundEvent[r.Header.Get("ce-type")]
-	return ok &&
+	ct := r.Header.Get(contentTypeHeader)
+	if strings.HasPrefix(ct, jsonContentType) || strings.HasPrefix(ct, batchContentType) {
+		return true
+	}
+	return r.Header.Get("ce-type") != "" &&
 		r.Header.Get("ce-source") != "" &&
 		r.Header.Get("ce-specversion") != "" &&
 		r.Header.Get("ce-id") != ""
 }
 
-func convertCloudEventToBackgroundRequest(r *http.Request) error {
+// readCloudEventsFromRequest reads the CloudEvents carried by r. Binary and
+// structured mode requests carry a single event, batch mode requests carry
+// any number of events.
+func readCloudEventsFromRequest(r *http.Request) ([]cloudEventContext, error) {
 	body, err := readHTTPRequestBody(r)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var data map[string]interface{}
-	if err := json.Unmarshal(body, &data); err != nil {
-		return fmt.Errorf("unable to unmarshal CloudEvent data: %s, error: %v", string(body), err)
+	ct := r.Header.Get(contentTypeHeader)
+	switch {
+	case strings.HasPrefix(ct, batchContentType):
+		var batch []map[string]json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal CloudEvent batch: %s, error: %v", string(body), err)
+		}
+		events := make([]cloudEventContext, 0, len(batch))
+		for i, attrs := range batch {
+			ce, err := structuredCloudEvent(attrs)
+			if err != nil {
+				return nil, fmt.Errorf("CloudEvent %d in batch: %v", i, err)
+			}
+			events = append(events, ce)
+		}
+		return events, nil
+	case strings.HasPrefix(ct, jsonContentType):
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(body, &attrs); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal structured CloudEvent: %s, error: %v", string(body), err)
+		}
+		ce, err := structuredCloudEvent(attrs)
+		if err != nil {
+			return nil, err
+		}
+		return []cloudEventContext{ce}, nil
+	}
+
+	return []cloudEventContext{{
+		Type:       binaryCloudEventAttribute(r.Header, "ce-type"),
+		Source:     binaryCloudEventAttribute(r.Header, "ce-source"),
+		Subject:    binaryCloudEventAttribute(r.Header, "ce-subject"),
+		Id:         binaryCloudEventAttribute(r.Header, "ce-id"),
+		Time:       binaryCloudEventAttribute(r.Header, "ce-time"),
+		Data:       body,
+		Extensions: binaryCloudEventExtensions(r.Header),
+	}}, nil
+}
+
+// binaryCloudEventExtensions returns the extension attributes carried as
+// "ce-" prefixed headers of a binary mode CloudEvent.
+func binaryCloudEventExtensions(h http.Header) map[string]string {
+	var ext map[string]string
+	for k, v := range h {
+		name := strings.ToLower(k)
+		if !strings.HasPrefix(name, "ce-") || len(v) == 0 {
+			continue
+		}
+		name = strings.TrimPrefix(name, "ce-")
+		if cloudEventAttributes[name] {
+			continue
+		}
+		if ext == nil {
+			ext = map[string]string{}
+		}
+		ext[name] = decodeBinaryCloudEventHeader(v[0])
+	}
+	return ext
+}
+
+// binaryCloudEventAttribute returns the decoded value of the header carrying
+// an attribute of a binary mode CloudEvent.
+func binaryCloudEventAttribute(h http.Header, name string) string {
+	return decodeBinaryCloudEventHeader(h.Get(name))
+}
+
+// decodeBinaryCloudEventHeader decodes the value of a "ce-" prefixed header,
+// which is percent-encoded by the HTTP protocol binding, see
+// https://github.com/cloudevents/spec/blob/v1.0.2/cloudevents/bindings/http-protocol-binding.md#3132-http-header-values.
+// Values that are not validly encoded are returned unchanged.
+func decodeBinaryCloudEventHeader(v string) string {
+	decoded, err := url.PathUnescape(v)
+	if err != nil {
+		return v
+	}
+	return decoded
+}
+
+// structuredCloudEvent builds a cloudEventContext from the top-level members
+// of a structured mode CloudEvent.
+func structuredCloudEvent(attrs map[string]json.RawMessage) (cloudEventContext, error) {
+	var ce cloudEventContext
+	for name, dst := range map[string]*string{
+		"type":    &ce.Type,
+		"source":  &ce.Source,
+		"subject": &ce.Subject,
+		"id":      &ce.Id,
+		"time":    &ce.Time,
+	} {
+		raw, ok := attrs[name]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(raw, dst); err != nil {
+			return ce, fmt.Errorf("invalid CloudEvent attribute %q: %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"specversion", "type", "source", "id"} {
+		if _, ok := attrs[name]; !ok {
+			return ce, fmt.Errorf("CloudEvent is missing required attribute %q", name)
+		}
+	}
+
+	for name, raw := range attrs {
+		if cloudEventAttributes[name] {
+			continue
+		}
+		if ce.Extensions == nil {
+			ce.Extensions = map[string]string{}
+		}
+		// Non-string extension values, e.g. integers and booleans, keep their
+		// JSON representation.
+		var str string
+		if err := json.Unmarshal(raw, &str); err == nil {
+			ce.Extensions[name] = str
+		} else {
+			ce.Extensions[name] = string(raw)
+		}
+	}
+
+	if raw, ok := attrs["data_base64"]; ok {
+		var encoded string
+		if err := json.Unmarshal(raw, &encoded); err != nil {
+			return ce, fmt.Errorf("invalid CloudEvent attribute \"data_base64\": %v", err)
+		}
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return ce, fmt.Errorf("unable to decode CloudEvent \"data_base64\": %v", err)
+		}
+		ce.Data = data
+	} else {
+		ce.Data = attrs["data"]
 	}
+	return ce, nil
+}
 
-	ceCtx := struct {
-		Type    string
-		Source  string
-		Subject string
-		Id      string
-		Time    string
-	}{
-		Type:    r.Header.Get("ce-type"),
-		Source:  r.Header.Get("ce-source"),
-		Subject: r.Header.Get("ce-subject"),
-		Id:      r.Header.Get("ce-id"),
-		Time:    r.Header.Get("ce-time"),
+// convertCloudEventToBackgroundRequests converts a CloudEvent request in any
+// content mode into one background event request per CloudEvent, in order.
+func convertCloudEventToBackgroundRequests(r *http.Request) ([]*http.Request, error) {
+	events, err := readCloudEventsFromRequest(r)
+	if err != nil {
+		return nil, err
 	}
 
+	reqs := make([]*http.Request, 0, len(events))
+	for _, ce := range events {
+		encoded, err := convertCloudEventToBackgroundEvent(ce)
+		if err != nil {
+			if len(events) > 1 {
+				return nil, fmt.Errorf("CloudEvent %q in batch: %v", ce.Id, err)
+			}
+			return nil, err
+		}
+		req := r.Clone(contextWithCloudEventExtensions(r.Context(), ce))
+		setBackgroundEventBody(req, encoded)
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
+func setBackgroundEventBody(r *http.Request, encoded []byte) {
+	r.Body = ioutil.NopCloser(bytes.NewReader(encoded))
+	r.Header.Set(contentTypeHeader, jsonContentType)
+	r.Header.Set(contentLengthHeader, fmt.Sprint(len(encoded)))
+}
+
+// convertCloudEventToBackgroundEvent returns the JSON encoded background event
+// equivalent to ceCtx.
+func convertCloudEventToBackgroundEvent(ceCtx cloudEventContext) ([]byte, error) {
 	eventType, ok := typeCloudToBackgroundEvent[ceCtx.Type]
 	if !ok {
-		return fmt.Errorf("incoming event has unsupported event type: %q", ceCtx.Type)
+		return nil, fmt.Errorf("CloudEvent type %q has no background event equivalent and cannot be delivered to a background event function", ceCtx.Type)
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(ceCtx.Data, &data); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal CloudEvent data: %s, error: %v", string(ceCtx.Data), err)
 	}
 
 	/*
@@ -446,7 +724,7 @@ func convertCloudEventToBackgroundRequest(r *http.Request) error {
 	*/
 	matches := regexp.MustCompile(`//([^/]+)/(.+)`).FindStringSubmatch(ceCtx.Source)
 	if len(matches) != 3 {
-		return fmt.Errorf("unable to parse CloudEvent source into resource service and name: %q", ceCtx.Source)
+		return nil, fmt.Errorf("unable to parse CloudEvent source into resource service and name: %q", ceCtx.Source)
 	}
 
 	// 0th match is the entire input string
@@ -536,11 +814,7 @@ func convertCloudEventToBackgroundRequest(r *http.Request) error {
 
 	encoded, err := json.Marshal(be)
 	if err != nil {
-		return fmt.Errorf("unable to marshal Background event %v: %v", be, err)
+		return nil, fmt.Errorf("unable to marshal Background event %v: %v", be, err)
 	}
-
-	r.Body = ioutil.NopCloser(bytes.NewReader(encoded))
-	r.Header.Set(contentTypeHeader, jsonContentType)
-	r.Header.Set(contentLengthHeader, fmt.Sprint(len(encoded)))
-	return nil
+	return encoded, nil
 }
@@ -0,0 +1,254 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// The conversion corpus in testdata/conversions has one directory per
+// background event type, holding:
+//
+//   - background.json: a background event, the input.
+//   - cloudevent.json: the CloudEvent it must be converted to.
+//
+// The expected CloudEvents follow the mapping of background events to
+// CloudEvents of Cloud Functions, which the Functions Frameworks for other
+// languages implement as well. Along with an intended change of the
+// conversions, rewrite the CloudEvents that no longer match with:
+//
+//	go test ./funcframework -run TestConversionCorpus -update
+//
+// and review the diff of each rewritten file against that mapping, as it
+// becomes the expected output as is.
+const conversionsDir = "testdata/conversions"
+
+var update = flag.Bool("update", false, "rewrite the CloudEvents of the conversion corpus that do not match")
+
+// lossyConversionFields lists, by corpus directory, the fields of the
+// background event that do not survive the round trip to a CloudEvent and
+// back. Every other field must be converted back to its original value.
+var lossyConversionFields = map[string][]string{
+	// providers/cloud.pubsub/eventTypes/topic.publish is converted to the
+	// CloudEvent type of google.pubsub.topic.publish, which is what that
+	// CloudEvent is converted back to. The string resource comes back as the
+	// structured resource of google.pubsub.topic.publish, with the service
+	// and message type added.
+	"pubsub-legacy-publish": {"context.eventType", "context.resource"},
+	// providers/cloud.storage/eventTypes/object.change is converted to a
+	// finalized object CloudEvent, which is converted back to
+	// google.storage.object.finalize, with a structured resource naming the
+	// storage service and the storage#object type.
+	"storage-legacy-change": {"context.eventType", "context.resource"},
+	// The firebaseio.com domain only selects the us-central1 location of the
+	// CloudEvent source. Background events converted from CloudEvents have no
+	// domain field.
+	"firebase-db-create": {"domain"},
+	// europe-west1.firebasedatabase.app only provides the europe-west1
+	// location in the CloudEvent source, and the domain is not rebuilt from
+	// the location when converting back.
+	"firebase-db-write": {"domain"},
+	// The domain is reduced to the locations/europe-west1 segment of the
+	// CloudEvent source, which is removed from the resource of the converted
+	// background event.
+	"firebase-db-update": {"domain"},
+	// CloudEvents have no domain attribute: the domain of the event of the
+	// deleted reference is only kept as the location in the source, which is
+	// not mapped back to a domain.
+	"firebase-db-delete": {"domain"},
+}
+
+// conversionCase is a directory of the conversion corpus.
+type conversionCase struct {
+	name string
+	dir  string
+}
+
+func conversionCases(t *testing.T) []conversionCase {
+	t.Helper()
+	entries, err := os.ReadDir(conversionsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []conversionCase
+	for _, e := range entries {
+		if e.IsDir() {
+			cases = append(cases, conversionCase{e.Name(), filepath.Join(conversionsDir, e.Name())})
+		}
+	}
+	return cases
+}
+
+func (c conversionCase) read(t *testing.T, file string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(c.dir, file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// convert converts the background event of c to a CloudEvent and back.
+func (c conversionCase) convert(t *testing.T) (cloudEvent, background []byte) {
+	t.Helper()
+	cloudEvent, err := convertBackgroundEventToCloudEvent(c.read(t, "background.json"), "/")
+	if err != nil {
+		t.Fatalf("converting background event to CloudEvent: %v", err)
+	}
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(cloudEvent, &attrs); err != nil {
+		t.Fatal(err)
+	}
+	ce, err := structuredCloudEvent(attrs)
+	if err != nil {
+		t.Fatalf("reading CloudEvent: %v", err)
+	}
+	background, err = convertCloudEventToBackgroundEvent(ce)
+	if err != nil {
+		t.Fatalf("converting CloudEvent to background event: %v", err)
+	}
+	return cloudEvent, background
+}
+
+func TestConversionCorpus(t *testing.T) {
+	for _, c := range conversionCases(t) {
+		t.Run(c.name, func(t *testing.T) {
+			cloudEvent, _ := c.convert(t)
+			var want, got interface{}
+			if err := json.Unmarshal(c.read(t, "cloudevent.json"), &want); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(cloudEvent, &got); err != nil {
+				t.Fatal(err)
+			}
+			diff := cmp.Diff(want, got)
+			if diff == "" {
+				return
+			}
+			if *update {
+				var indented bytes.Buffer
+				if err := json.Indent(&indented, cloudEvent, "", "  "); err != nil {
+					t.Fatal(err)
+				}
+				indented.WriteByte('\n')
+				if err := os.WriteFile(filepath.Join(c.dir, "cloudevent.json"), indented.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			t.Errorf("CloudEvent mismatch (-want +got), run with -update and review the diff if intended:\n%s", diff)
+		})
+	}
+}
+
+func TestConversionCorpusCoverage(t *testing.T) {
+	backgroundTypes := map[string]bool{}
+	cloudEventTypes := map[string]bool{}
+	for _, c := range conversionCases(t) {
+		var be struct {
+			Context struct {
+				EventType string `json:"eventType"`
+			} `json:"context"`
+		}
+		if err := json.Unmarshal(c.read(t, "background.json"), &be); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		var ce struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(c.read(t, "cloudevent.json"), &ce); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		backgroundTypes[be.Context.EventType] = true
+		cloudEventTypes[ce.Type] = true
+	}
+
+	for typ := range typeBackgroundToCloudEvent {
+		if !backgroundTypes[typ] {
+			t.Errorf("no background event of type %q in %s", typ, conversionsDir)
+		}
+	}
+	for typ := range typeCloudToBackgroundEvent {
+		if !cloudEventTypes[typ] {
+			t.Errorf("no CloudEvent of type %q in %s", typ, conversionsDir)
+		}
+	}
+}
+
+func TestConversionRoundTrip(t *testing.T) {
+	for _, c := range conversionCases(t) {
+		t.Run(c.name, func(t *testing.T) {
+			_, background := c.convert(t)
+			var want, got interface{}
+			if err := json.Unmarshal(c.read(t, "background.json"), &want); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(background, &got); err != nil {
+				t.Fatal(err)
+			}
+
+			lost := jsonDiffPaths("", want, got)
+			if diff := cmp.Diff(lossyConversionFields[c.name], lost); diff != "" {
+				t.Errorf("fields changed by the round trip (-documented +got), update lossyConversionFields if intended:\n%s", diff)
+			}
+		})
+	}
+}
+
+// jsonDiffPaths returns the sorted dot-separated paths of the fields that
+// differ between the decoded JSON values a and b, or nil if they are equal.
+func jsonDiffPaths(path string, a, b interface{}) []string {
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if !aok || !bok {
+		if reflect.DeepEqual(a, b) {
+			return nil
+		}
+		return []string{path}
+	}
+
+	var paths []string
+	for k, av := range am {
+		bv, ok := bm[k]
+		if !ok {
+			paths = append(paths, joinPath(path, k))
+			continue
+		}
+		paths = append(paths, jsonDiffPaths(joinPath(path, k), av, bv)...)
+	}
+	for k := range bm {
+		if _, ok := am[k]; !ok {
+			paths = append(paths, joinPath(path, k))
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -3,10 +3,12 @@ package funcframework
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -752,9 +754,14 @@ func TestConvertCloudEventToBackgroundRequest(t *testing.T) {
 			req.Header.Set("ce-time", ce.Time().Format(time.RFC3339Nano))
 			req.Header.Set("ce-specversion", ce.SpecVersion())
 
-			if err := convertCloudEventToBackgroundRequest(req); err != nil {
+			reqs, err := convertCloudEventToBackgroundRequests(req)
+			if err != nil {
 				t.Fatalf("unexpected error converting CloudEvent to Background event request: %v", err)
 			}
+			if len(reqs) != 1 {
+				t.Fatalf("convertCloudEventToBackgroundRequests() returned %d requests, want 1", len(reqs))
+			}
+			req = reqs[0]
 
 			gotBody, err := ioutil.ReadAll(req.Body)
 			if err != nil {
@@ -787,6 +794,282 @@ func TestConvertCloudEventToBackgroundRequest(t *testing.T) {
 	}
 }
 
+func TestConvertCloudEventToBackgroundRequestContentModes(t *testing.T) {
+	storageData := `{"bucket":"some-bucket","kind":"storage#object","name":"folder/Test.cs"}`
+	storageBE := `{
+		"context": {
+		   "eventId": "aaaaaa-1111-bbbb-2222-cccccccccccc",
+		   "timestamp": "2020-09-29T11:32:00.000Z",
+		   "eventType": "google.storage.object.finalize",
+		   "resource": {
+			  "service": "storage.googleapis.com",
+			  "name": "projects/_/buckets/some-bucket/objects/folder/Test.cs",
+			  "type": "storage#object"
+		   }
+		},
+		"data": ` + storageData + `
+	}`
+	structuredStorageCE := `{
+		"specversion": "1.0",
+		"type": "google.cloud.storage.object.v1.finalized",
+		"source": "//storage.googleapis.com/projects/_/buckets/some-bucket",
+		"subject": "objects/folder/Test.cs",
+		"id": "aaaaaa-1111-bbbb-2222-cccccccccccc",
+		"time": "2020-09-29T11:32:00.000Z",
+		"datacontenttype": "application/json",
+		"data": ` + storageData + `
+	}`
+
+	tcs := []struct {
+		name        string
+		contentType string
+		headers     map[string]string
+		body        string
+		wantBEs     []string
+		wantErr     string
+	}{
+		{
+			name:        "structured",
+			contentType: "application/cloudevents+json; charset=utf-8",
+			body:        structuredStorageCE,
+			wantBEs:     []string{storageBE},
+		},
+		{
+			name:        "structured with base64 data",
+			contentType: "application/cloudevents+json",
+			body: `{
+				"specversion": "1.0",
+				"type": "google.cloud.storage.object.v1.finalized",
+				"source": "//storage.googleapis.com/projects/_/buckets/some-bucket",
+				"subject": "objects/folder/Test.cs",
+				"id": "aaaaaa-1111-bbbb-2222-cccccccccccc",
+				"time": "2020-09-29T11:32:00.000Z",
+				"data_base64": "` + base64.StdEncoding.EncodeToString([]byte(storageData)) + `"
+			}`,
+			wantBEs: []string{storageBE},
+		},
+		{
+			name:        "batch",
+			contentType: "application/cloudevents-batch+json",
+			body:        "[" + structuredStorageCE + "," + structuredStorageCE + "]",
+			wantBEs:     []string{storageBE, storageBE},
+		},
+		{
+			name:        "empty batch",
+			contentType: "application/cloudevents-batch+json",
+			body:        "[]",
+		},
+		{
+			name: "binary unmapped type",
+			headers: map[string]string{
+				"ce-specversion": "1.0",
+				"ce-type":        "com.example.unknown",
+				"ce-source":      "//example.com/source",
+				"ce-id":          "1234",
+			},
+			body:    `{}`,
+			wantErr: `CloudEvent type "com.example.unknown" has no background event equivalent`,
+		},
+		{
+			name:        "structured unmapped type",
+			contentType: "application/cloudevents+json",
+			body: `{
+				"specversion": "1.0",
+				"type": "com.example.unknown",
+				"source": "//example.com/source",
+				"id": "1234",
+				"data": {}
+			}`,
+			wantErr: `CloudEvent type "com.example.unknown" has no background event equivalent`,
+		},
+		{
+			name:        "structured missing id",
+			contentType: "application/cloudevents+json",
+			body: `{
+				"specversion": "1.0",
+				"type": "google.cloud.storage.object.v1.finalized",
+				"source": "//storage.googleapis.com/projects/_/buckets/some-bucket",
+				"data": {}
+			}`,
+			wantErr: `CloudEvent is missing required attribute "id"`,
+		},
+		{
+			name:        "batch with unmapped type",
+			contentType: "application/cloudevents-batch+json",
+			body: "[" + structuredStorageCE + `, {
+				"specversion": "1.0",
+				"type": "com.example.unknown",
+				"source": "//example.com/source",
+				"id": "1234",
+				"data": {}
+			}]`,
+			wantErr: `CloudEvent "1234" in batch: CloudEvent type "com.example.unknown" has no background event equivalent`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "example.com", bytes.NewBufferString(tc.body))
+			if err != nil {
+				t.Fatalf("unable to create test request data: %v", err)
+			}
+			if tc.contentType != "" {
+				req.Header.Set(contentTypeHeader, tc.contentType)
+			}
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+
+			if !shouldConvertCloudEventToBackgroundRequest(req) {
+				t.Fatalf("shouldConvertCloudEventToBackgroundRequest() = false, want true")
+			}
+
+			reqs, err := convertCloudEventToBackgroundRequests(req)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("convertCloudEventToBackgroundRequests() error = %v, want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error converting CloudEvent to Background event requests: %v", err)
+			}
+
+			if len(reqs) != len(tc.wantBEs) {
+				t.Fatalf("convertCloudEventToBackgroundRequests() returned %d requests, want %d", len(reqs), len(tc.wantBEs))
+			}
+			for i, r := range reqs {
+				gotBody, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Fatalf("unable to read got request body: %v", err)
+				}
+				var wantObj, gotObj map[string]interface{}
+				if err := json.Unmarshal([]byte(tc.wantBEs[i]), &wantObj); err != nil {
+					t.Fatalf("test wantBE is invalid JSON: %q, err: %v", tc.wantBEs[i], err)
+				}
+				if err := json.Unmarshal(gotBody, &gotObj); err != nil {
+					t.Fatalf("convertCloudEventToBackgroundRequests() created invalid JSON: %q, err: %v", string(gotBody), err)
+				}
+				if diff := cmp.Diff(wantObj, gotObj); diff != "" {
+					t.Errorf("convertCloudEventToBackgroundRequests() request %d mismatch (-want +got):\n%s", i, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertCloudEventToBackgroundRequestExtensions(t *testing.T) {
+	tcs := []struct {
+		name        string
+		contentType string
+		headers     map[string]string
+		body        string
+		wantExt     map[string]string
+		wantTraceID string
+		wantSpanID  string
+	}{
+		{
+			name: "binary",
+			headers: map[string]string{
+				"ce-specversion":        "1.0",
+				"ce-type":               "google.cloud.pubsub.topic.v1.messagePublished",
+				"ce-source":             "//pubsub.googleapis.com/projects/sample-project/topics/gcf-test",
+				"ce-id":                 "1234",
+				"ce-traceparent":        "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				"ce-knativearrivaltime": "2020-09-29T11:32:00.123Z",
+				"Content-Type":          "application/json",
+			},
+			body: `{"message": {"data": "dGVzdA=="}}`,
+			wantExt: map[string]string{
+				"traceparent":        "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				"knativearrivaltime": "2020-09-29T11:32:00.123Z",
+			},
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantSpanID:  "00f067aa0ba902b7",
+		},
+		{
+			name:        "structured",
+			contentType: "application/cloudevents+json",
+			body: `{
+				"specversion": "1.0",
+				"type": "google.cloud.pubsub.topic.v1.messagePublished",
+				"source": "//pubsub.googleapis.com/projects/sample-project/topics/gcf-test",
+				"id": "1234",
+				"datacontenttype": "application/json",
+				"comexampleextension": "value",
+				"comexamplecount": 42,
+				"data": {"message": {"data": "dGVzdA=="}}
+			}`,
+			wantExt: map[string]string{
+				"comexampleextension": "value",
+				"comexamplecount":     "42",
+			},
+		},
+		{
+			name: "binary percent-encoded",
+			headers: map[string]string{
+				"ce-specversion":       "1.0",
+				"ce-type":              "google.cloud.pubsub.topic.v1.messagePublished",
+				"ce-source":            "//pubsub.googleapis.com/projects/sample-project/topics/gcf-test",
+				"ce-id":                "1234",
+				"ce-comexampleext":     "Euro%20%E2%82%AC%20%F0%9F%98%80%20100%25+tax",
+				"ce-comexampleinvalid": "100%",
+				"Content-Type":         "application/json",
+			},
+			body: `{"message": {"data": "dGVzdA=="}}`,
+			wantExt: map[string]string{
+				"comexampleext":     "Euro € 😀 100%+tax",
+				"comexampleinvalid": "100%",
+			},
+		},
+		{
+			name: "no extensions",
+			headers: map[string]string{
+				"ce-specversion": "1.0",
+				"ce-type":        "google.cloud.pubsub.topic.v1.messagePublished",
+				"ce-source":      "//pubsub.googleapis.com/projects/sample-project/topics/gcf-test",
+				"ce-id":          "1234",
+				"Content-Type":   "application/json",
+			},
+			body: `{"message": {"data": "dGVzdA=="}}`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "example.com", bytes.NewBufferString(tc.body))
+			if err != nil {
+				t.Fatalf("unable to create test request data: %v", err)
+			}
+			if tc.contentType != "" {
+				req.Header.Set(contentTypeHeader, tc.contentType)
+			}
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+
+			reqs, err := convertCloudEventToBackgroundRequests(req)
+			if err != nil {
+				t.Fatalf("unexpected error converting CloudEvent to Background event requests: %v", err)
+			}
+			if len(reqs) != 1 {
+				t.Fatalf("convertCloudEventToBackgroundRequests() returned %d requests, want 1", len(reqs))
+			}
+
+			ctx := reqs[0].Context()
+			if diff := cmp.Diff(tc.wantExt, CloudEventExtensionsFromContext(ctx)); diff != "" {
+				t.Errorf("CloudEventExtensionsFromContext() mismatch (-want +got):\n%s", diff)
+			}
+			if got := TraceIDFromContext(ctx); got != tc.wantTraceID {
+				t.Errorf("TraceIDFromContext() = %q, want %q", got, tc.wantTraceID)
+			}
+			if got := SpanIDFromContext(ctx); got != tc.wantSpanID {
+				t.Errorf("SpanIDFromContext() = %q, want %q", got, tc.wantSpanID)
+			}
+		})
+	}
+}
+
 func TestShouldConvertCloudEventToBackgroundRequest(t *testing.T) {
 	tcs := []struct {
 		name          string
@@ -819,7 +1102,9 @@ func TestShouldConvertCloudEventToBackgroundRequest(t *testing.T) {
 			shouldConvert: true,
 		},
 		{
-			name: "invalid type",
+			// Unmapped types are rejected during conversion with a descriptive
+			// error rather than passed through to the function unconverted.
+			name: "unmapped type",
 			ceHeaderJSON: `{
 				"specversion": "1.0",
 				"type": "google.invalid.type",
@@ -829,7 +1114,7 @@ func TestShouldConvertCloudEventToBackgroundRequest(t *testing.T) {
 				"time": "2020-09-29T11:32:00.123Z",
 				"datacontenttype": "application/json"
 			  }`,
-			shouldConvert: false,
+			shouldConvert: true,
 		},
 		{
 			name: "missing specversion",
@@ -0,0 +1,313 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fftest invokes functions in unit tests the way the framework serves
+// them, without starting a server. Requests and events go through the same
+// decoding, conversion, error handling and logging as in production, for
+// example:
+//
+//	func TestHelloWorld(t *testing.T) {
+//	  res, err := fftest.InvokeHTTP("HelloWorld", httptest.NewRequest("GET", "/", nil))
+//	  if err != nil {
+//	    t.Fatal(err)
+//	  }
+//	  if string(res.Body) != "Hello, World!" {
+//	    t.Errorf("HelloWorld() = %q, want %q", res.Body, "Hello, World!")
+//	  }
+//	}
+//
+// The package-level functions invoke the functions registered with the
+// functions package. Functions defined by a test can be registered in a
+// Registry instead, so that they are isolated from each other and from the
+// functions served by the program.
+package fftest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/functions/metadata"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/GoogleCloudPlatform/functions-framework-go/internal/fftypes"
+	"github.com/GoogleCloudPlatform/functions-framework-go/internal/invoker"
+	"github.com/GoogleCloudPlatform/functions-framework-go/internal/registry"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+
+	// The framework provides the invoker when it is initialized.
+	_ "github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
+)
+
+// statusHeader is the header the framework sets on the response to a failed
+// invocation.
+const statusHeader = "X-Google-Status"
+
+// Result is the outcome of an invocation.
+type Result struct {
+	// Response is the response to the invocation. Its body has already been
+	// read into Body.
+	Response *http.Response
+	// Body is the body of the response.
+	Body []byte
+	// Status is the X-Google-Status header of the response: "error" if the
+	// function returned an error, "crash" if it panicked or could not be
+	// invoked, for example because the event could not be decoded, and empty
+	// otherwise. The errors of CloudEvent functions are only reflected by the
+	// status code of the response.
+	Status string
+	// Event is the event replied by a CloudEvent function registered with
+	// functions.CloudEventResponse, if any.
+	Event *cloudevents.Event
+	// Logs are the log entries written during the invocation by the function
+	// with funcframework.LogWriter or funcframework.Logger, and by the
+	// framework, such as error reports. Output written to stdout or stderr
+	// directly is not captured.
+	Logs []LogEntry
+}
+
+// DecodeJSON decodes the JSON body of the response into v, such as the
+// output of a typed function.
+func (r *Result) DecodeJSON(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// LogEntry is a log entry written during an invocation.
+type LogEntry struct {
+	// Severity is the severity of a structured entry.
+	Severity string
+	// Message is the message of a structured entry, or the line written for
+	// an unstructured one.
+	Message string
+	// Fields are all the fields of a structured entry, such as
+	// "logging.googleapis.com/labels". It is nil for unstructured entries.
+	Fields map[string]interface{}
+}
+
+// Registry is a set of functions, isolated from the functions registered with
+// the functions package.
+type Registry struct {
+	functions *registry.Registry
+
+	mu       sync.Mutex
+	handlers map[*registry.RegisteredFunction]http.Handler
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return newRegistry(registry.New())
+}
+
+func newRegistry(r *registry.Registry) *Registry {
+	return &Registry{
+		functions: r,
+		handlers:  map[*registry.RegisteredFunction]http.Handler{},
+	}
+}
+
+// defaultRegistry holds the functions registered with the functions package.
+var defaultRegistry = newRegistry(registry.Default())
+
+// HTTP registers an HTTP function called name, like functions.HTTP.
+func (r *Registry) HTTP(name string, fn func(http.ResponseWriter, *http.Request), options ...functions.Option) error {
+	return r.functions.RegisterHTTP(fn, append(options, registry.WithName(name))...)
+}
+
+// CloudEvent registers a CloudEvent function called name, like
+// functions.CloudEvent.
+func (r *Registry) CloudEvent(name string, fn func(context.Context, cloudevents.Event) error, options ...functions.Option) error {
+	return r.functions.RegisterCloudEvent(fn, append(options, registry.WithName(name))...)
+}
+
+// CloudEventResponse registers a CloudEvent function called name that may
+// reply with an event, like functions.CloudEventResponse.
+func (r *Registry) CloudEventResponse(name string, fn func(context.Context, cloudevents.Event) (*cloudevents.Event, error), options ...functions.Option) error {
+	return r.functions.RegisterCloudEventResponse(fn, append(options, registry.WithName(name))...)
+}
+
+// Event registers a background event function called name, like
+// funcframework.RegisterEventFunctionContext.
+func (r *Registry) Event(name string, fn interface{}, options ...functions.Option) error {
+	return r.functions.RegisterEvent(fn, append(options, registry.WithName(name))...)
+}
+
+// Typed registers a typed function called name, like functions.Typed.
+func (r *Registry) Typed(name string, fn interface{}, options ...functions.Option) error {
+	return r.functions.RegisterTyped(fn, append(options, registry.WithName(name))...)
+}
+
+// InvokeHTTP invokes the function called name registered with the functions
+// package with req, usually created with httptest.NewRequest.
+func InvokeHTTP(name string, req *http.Request) (*Result, error) {
+	return defaultRegistry.InvokeHTTP(name, req)
+}
+
+// InvokeCloudEvent invokes the function called name registered with the
+// functions package with event, sent in binary content mode.
+func InvokeCloudEvent(name string, event cloudevents.Event) (*Result, error) {
+	return defaultRegistry.InvokeCloudEvent(name, event)
+}
+
+// InvokeBackground invokes the function called name registered with the
+// functions package with the background event made of m and data.
+func InvokeBackground(name string, m *metadata.Metadata, data interface{}) (*Result, error) {
+	return defaultRegistry.InvokeBackground(name, m, data)
+}
+
+// InvokeTyped invokes the typed function called name registered with the
+// functions package with input, sent JSON encoded. The output of the function
+// can be read with Result.DecodeJSON.
+func InvokeTyped(name string, input interface{}) (*Result, error) {
+	return defaultRegistry.InvokeTyped(name, input)
+}
+
+// InvokeHTTP invokes the function called name with req, usually created with
+// httptest.NewRequest.
+func (r *Registry) InvokeHTTP(name string, req *http.Request) (*Result, error) {
+	return r.invoke(name, req)
+}
+
+// InvokeCloudEvent invokes the function called name with event, sent in
+// binary content mode.
+func (r *Registry) InvokeCloudEvent(name string, event cloudevents.Event) (*Result, error) {
+	if err := event.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid CloudEvent: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	ctx := binding.WithForceBinary(req.Context())
+	if err := cehttp.WriteRequest(ctx, binding.ToMessage(&event), req); err != nil {
+		return nil, fmt.Errorf("encoding CloudEvent: %v", err)
+	}
+	return r.invoke(name, req)
+}
+
+// InvokeBackground invokes the function called name with the background event
+// made of m and data.
+func (r *Registry) InvokeBackground(name string, m *metadata.Metadata, data interface{}) (*Result, error) {
+	if m == nil {
+		return nil, fmt.Errorf("missing event metadata")
+	}
+	body, err := json.Marshal(fftypes.BackgroundEvent{Data: data, Metadata: m})
+	if err != nil {
+		return nil, fmt.Errorf("encoding background event: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return r.invoke(name, req)
+}
+
+// InvokeTyped invokes the typed function called name with input, sent JSON
+// encoded. The output of the function can be read with Result.DecodeJSON.
+func (r *Registry) InvokeTyped(name string, input interface{}) (*Result, error) {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("encoding input: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return r.invoke(name, req)
+}
+
+// invoke serves req with the function called name and returns the result.
+func (r *Registry) invoke(name string, req *http.Request) (*Result, error) {
+	h, err := r.handler(name)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := &logBuffer{}
+	req = req.WithContext(invoker.ContextWithLogOutput(req.Context(), logs))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	resp := rec.Result()
+	res := &Result{
+		Response: resp,
+		Body:     rec.Body.Bytes(),
+		Status:   resp.Header.Get(statusHeader),
+		Logs:     logs.entries(),
+	}
+	if resp.Header.Get("Ce-Id") != "" || strings.HasPrefix(resp.Header.Get("Content-Type"), cloudevents.ApplicationCloudEventsJSON) {
+		event, err := cehttp.NewEventFromHTTPResponse(resp)
+		if err != nil {
+			return nil, fmt.Errorf("decoding response event: %v", err)
+		}
+		res.Event = event
+	}
+	return res, nil
+}
+
+// handler returns the handler serving the function called name, wrapping it
+// on first use so that state such as concurrency limits is kept across
+// invocations.
+func (r *Registry) handler(name string) (http.Handler, error) {
+	fn, ok := r.functions.GetRegisteredFunction(name)
+	if !ok {
+		return nil, fmt.Errorf("no function registered with name %q", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if h, ok := r.handlers[fn]; ok {
+		return h, nil
+	}
+	h, err := invoker.WrapFunction(fn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serve function %q: %v", name, err)
+	}
+	r.handlers[fn] = h
+	return h, nil
+}
+
+// logBuffer collects the log output of an invocation, which may be written
+// concurrently by goroutines started by the function.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// entries returns the log entries written so far, one per line: unstructured
+// output such as a stack trace logged locally spans several entries.
+func (b *logBuffer) entries() []LogEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var entries []LogEntry
+	for _, line := range strings.Split(b.buf.String(), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			entries = append(entries, LogEntry{Message: line})
+			continue
+		}
+		e := LogEntry{Fields: fields}
+		e.Severity, _ = fields["severity"].(string)
+		e.Message, _ = fields["message"].(string)
+		entries = append(entries, e)
+	}
+	return entries
+}
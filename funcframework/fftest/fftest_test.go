@@ -0,0 +1,263 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fftest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/functions/metadata"
+	"github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+func init() {
+	functions.HTTP("Hello", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Hello, %s!", r.URL.Query().Get("name"))
+	})
+}
+
+func TestInvokeHTTP(t *testing.T) {
+	res, err := InvokeHTTP("Hello", httptest.NewRequest("GET", "/?name=Gopher", nil))
+	if err != nil {
+		t.Fatalf("InvokeHTTP(): %v", err)
+	}
+	if got, want := string(res.Body), "Hello, Gopher!"; got != want {
+		t.Errorf("InvokeHTTP() body = %q, want %q", got, want)
+	}
+	if res.Response.StatusCode != http.StatusOK || res.Status != "" {
+		t.Errorf("InvokeHTTP() status = %d %q, want %d with no X-Google-Status", res.Response.StatusCode, res.Status, http.StatusOK)
+	}
+}
+
+func TestInvokeHTTPLogs(t *testing.T) {
+	r := NewRegistry()
+	if err := r.HTTP("Log", func(w http.ResponseWriter, r *http.Request) {
+		l := log.New(funcframework.LogWriterWithSeverity(r.Context(), funcframework.SeverityWarning), "", 0)
+		l.Println("first")
+		funcframework.Logger(r.Context()).Info("second", "user", "gopher")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Function-Execution-Id", "exec-1")
+	res, err := r.InvokeHTTP("Log", req)
+	if err != nil {
+		t.Fatalf("InvokeHTTP(): %v", err)
+	}
+	if len(res.Logs) != 2 {
+		t.Fatalf("InvokeHTTP() logs = %+v, want 2 entries", res.Logs)
+	}
+	if e := res.Logs[0]; e.Severity != "WARNING" || e.Message != "first" {
+		t.Errorf("first log entry = %+v, want WARNING %q", e, "first")
+	}
+	if e := res.Logs[1]; e.Severity != "INFO" || e.Message != "second" || e.Fields["user"] != "gopher" {
+		t.Errorf("second log entry = %+v, want INFO %q with user gopher", e, "second")
+	}
+	for _, e := range res.Logs {
+		labels, _ := e.Fields["logging.googleapis.com/labels"].(map[string]interface{})
+		if labels["execution_id"] != "exec-1" {
+			t.Errorf("log entry %q labels = %v, want execution_id exec-1", e.Message, labels)
+		}
+	}
+}
+
+func TestInvokeCloudEvent(t *testing.T) {
+	r := NewRegistry()
+	if err := r.CloudEvent("Fail", func(ctx context.Context, e cloudevents.Event) error {
+		return fmt.Errorf("cannot handle %s", e.Type())
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CloudEventResponse("Echo", func(ctx context.Context, e cloudevents.Event) (*cloudevents.Event, error) {
+		reply := cloudevents.NewEvent()
+		reply.SetID("reply-" + e.ID())
+		reply.SetSource("//echo")
+		reply.SetType("com.example.reply")
+		if err := reply.SetData(cloudevents.ApplicationJSON, map[string]string{"subject": e.Subject()}); err != nil {
+			return nil, err
+		}
+		return &reply, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	event := cloudevents.NewEvent()
+	event.SetID("1")
+	event.SetSource("//storage.googleapis.com/projects/_/buckets/bucket")
+	event.SetSubject("objects/file.txt")
+	event.SetType("google.cloud.storage.object.v1.finalized")
+	if err := event.SetData(cloudevents.ApplicationJSON, map[string]string{"name": "file.txt"}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := r.InvokeCloudEvent("Fail", event)
+	if err != nil {
+		t.Fatalf("InvokeCloudEvent(): %v", err)
+	}
+	if res.Response.StatusCode != http.StatusInternalServerError {
+		t.Errorf("InvokeCloudEvent() status = %d, want %d", res.Response.StatusCode, http.StatusInternalServerError)
+	}
+	if len(res.Logs) == 0 || !strings.Contains(res.Logs[0].Message, "cannot handle google.cloud.storage.object.v1.finalized") {
+		t.Errorf("InvokeCloudEvent() logs = %+v, want the function error", res.Logs)
+	}
+
+	res, err = r.InvokeCloudEvent("Echo", event)
+	if err != nil {
+		t.Fatalf("InvokeCloudEvent(): %v", err)
+	}
+	if res.Event == nil {
+		t.Fatalf("InvokeCloudEvent() event = nil, want the reply (response %d %s)", res.Response.StatusCode, res.Body)
+	}
+	if res.Event.ID() != "reply-1" || string(res.Event.Data()) != `{"subject":"objects/file.txt"}` {
+		t.Errorf("InvokeCloudEvent() event = %v, want the reply", res.Event)
+	}
+
+	if _, err := r.InvokeCloudEvent("Echo", cloudevents.NewEvent()); err == nil {
+		t.Errorf("InvokeCloudEvent() with invalid event error = nil, want error")
+	}
+}
+
+func TestInvokeBackground(t *testing.T) {
+	type message struct {
+		Data []byte `json:"data"`
+	}
+	var got *metadata.Metadata
+	var gotData string
+	r := NewRegistry()
+	if err := r.Event("Background", func(ctx context.Context, m message) error {
+		var err error
+		got, err = metadata.FromContext(ctx)
+		gotData = string(m.Data)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &metadata.Metadata{
+		EventID:   "1",
+		Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		EventType: "google.pubsub.topic.publish",
+		Resource: &metadata.Resource{
+			Service: "pubsub.googleapis.com",
+			Name:    "projects/p/topics/t",
+		},
+	}
+	res, err := r.InvokeBackground("Background", m, message{Data: []byte("hello")})
+	if err != nil {
+		t.Fatalf("InvokeBackground(): %v", err)
+	}
+	if res.Response.StatusCode != http.StatusOK {
+		t.Fatalf("InvokeBackground() status = %d, want %d: %s", res.Response.StatusCode, http.StatusOK, res.Body)
+	}
+	if got == nil || got.EventID != "1" || got.EventType != m.EventType || !got.Timestamp.Equal(m.Timestamp) || got.Resource.Name != m.Resource.Name {
+		t.Errorf("function received metadata %+v, want %+v", got, m)
+	}
+	if gotData != "hello" {
+		t.Errorf("function received data %q, want %q", gotData, "hello")
+	}
+}
+
+func TestInvokeTyped(t *testing.T) {
+	type input struct {
+		Name string `json:"name"`
+	}
+	type output struct {
+		Greeting string `json:"greeting"`
+	}
+	r := NewRegistry()
+	if err := r.Typed("Greet", func(in input) (output, error) {
+		if in.Name == "" {
+			return output{}, errors.New("missing name")
+		}
+		return output{Greeting: "Hello, " + in.Name + "!"}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := r.InvokeTyped("Greet", input{Name: "Gopher"})
+	if err != nil {
+		t.Fatalf("InvokeTyped(): %v", err)
+	}
+	var out output
+	if err := res.DecodeJSON(&out); err != nil {
+		t.Fatalf("DecodeJSON(): %v", err)
+	}
+	if out.Greeting != "Hello, Gopher!" {
+		t.Errorf("InvokeTyped() output = %+v, want greeting %q", out, "Hello, Gopher!")
+	}
+
+	res, err = r.InvokeTyped("Greet", input{})
+	if err != nil {
+		t.Fatalf("InvokeTyped(): %v", err)
+	}
+	if res.Status != "error" {
+		t.Errorf("InvokeTyped() status = %q, want %q", res.Status, "error")
+	}
+}
+
+func TestRegistryIsolation(t *testing.T) {
+	r := NewRegistry()
+	if _, err := r.InvokeHTTP("Hello", httptest.NewRequest("GET", "/", nil)); err == nil {
+		t.Errorf("InvokeHTTP() of a function registered with the functions package in a new registry error = nil, want error")
+	}
+	if err := r.HTTP("Isolated", func(w http.ResponseWriter, r *http.Request) {}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InvokeHTTP("Isolated", httptest.NewRequest("GET", "/", nil)); err == nil {
+		t.Errorf("InvokeHTTP() of a function registered in a registry error = nil, want error")
+	}
+}
+
+func TestInvokeConcurrencyLimit(t *testing.T) {
+	r := NewRegistry()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	if err := r.HTTP("Limited", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}, functions.WithMaxConcurrency(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.InvokeHTTP("Limited", httptest.NewRequest("GET", "/", nil))
+	}()
+	<-started
+
+	// The limit is shared by the invocations of the function.
+	res, err := r.InvokeHTTP("Limited", httptest.NewRequest("GET", "/", nil))
+	close(release)
+	<-done
+	if err != nil {
+		t.Fatalf("InvokeHTTP(): %v", err)
+	}
+	if res.Response.StatusCode != http.StatusTooManyRequests {
+		body, _ := io.ReadAll(res.Response.Body)
+		t.Errorf("InvokeHTTP() status = %d, want %d: %s", res.Response.StatusCode, http.StatusTooManyRequests, body)
+	}
+}
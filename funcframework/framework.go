@@ -18,17 +18,22 @@ package funcframework
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"reflect"
 	"runtime/debug"
-	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/GoogleCloudPlatform/functions-framework-go/internal/netutil"
 	"github.com/GoogleCloudPlatform/functions-framework-go/internal/registry"
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 )
@@ -39,33 +44,55 @@ const (
 	errorStatus              = "error"
 	panicMessageTmpl         = "A panic occurred during %s. Please see logs for more details."
 	fnErrorMessageStderrTmpl = "Function error: %v"
+
+	httpSignatureType       = "http"
+	eventSignatureType      = "event"
+	cloudEventSignatureType = "cloudevent"
+	typedSignatureType      = "typed"
+
+	// shutdownTimeout bounds how long in-flight invocations are waited for
+	// when the server is shut down. It matches the delay between SIGTERM and
+	// SIGKILL on Cloud Run.
+	shutdownTimeout = 10 * time.Second
 )
 
-var errorType = reflect.TypeOf((*error)(nil)).Elem()
+var (
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
 
-// recoverPanic recovers from a panic in a consistent manner. panicSrc should
-// describe what was happening when the panic was encountered, for example
-// "user function execution". w is an http.ResponseWriter to write a generic
-// response body to that does not expose the details of the panic; w can be
-// nil to skip this. If panic needs to be recovered by different caller
-// set shouldPanic to true.
-func recoverPanic(w http.ResponseWriter, panicSrc string, shouldPanic bool) {
+// recoverPanic recovers from a panic in a consistent manner. ctx is the
+// context of the invocation, if any, and is used to record the panic on the
+// invocation's trace span. panicSrc should describe what was happening when
+// the panic was encountered, for example "user function execution". w is an
+// http.ResponseWriter to write a generic response body to that does not
+// expose the details of the panic; w can be nil to skip this.
+func recoverPanic(ctx context.Context, w http.ResponseWriter, panicSrc string) {
 	if r := recover(); r != nil {
-		genericMsg := fmt.Sprintf(panicMessageTmpl, panicSrc)
-		fmt.Fprintf(os.Stderr, "%s\npanic message: %v\nstack trace: %v\n%s", genericMsg, r, r, debug.Stack())
-		if w != nil {
-			writeHTTPErrorResponse(w, http.StatusInternalServerError, crashStatus, genericMsg)
-		}
-		if shouldPanic {
-			panic(r)
-		}
+		handlePanic(ctx, w, panicSrc, r)
+	}
+}
+
+// handlePanic reports the panic value r recovered during panicSrc, as
+// described for recoverPanic, and returns an error with the generic message
+// of the response. It is called by deferred functions that must recover the
+// panic themselves, for example to return the error.
+func handlePanic(ctx context.Context, w http.ResponseWriter, panicSrc string, r interface{}) error {
+	genericMsg := fmt.Sprintf(panicMessageTmpl, panicSrc)
+	reportPanic(ctx, genericMsg, r, debug.Stack())
+	recordFunctionPanic(ctx, r)
+	// The panic was already reported above, the response must not log it
+	// a second time.
+	if w != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, crashStatus, genericMsg)
 	}
+	return errors.New(genericMsg)
 }
 
 // RegisterHTTPFunction registers fn as an HTTP function.
 // Maintained for backward compatibility. Please use RegisterHTTPFunctionContext instead.
 func RegisterHTTPFunction(path string, fn interface{}) {
-	defer recoverPanic(nil, "function registration", false)
+	defer recoverPanic(context.Background(), nil, "function registration")
 
 	fnHTTP, ok := fn.(func(http.ResponseWriter, *http.Request))
 	if !ok {
@@ -82,7 +109,7 @@ func RegisterHTTPFunction(path string, fn interface{}) {
 // Maintained for backward compatibility. Please use RegisterEventFunctionContext instead.
 func RegisterEventFunction(path string, fn interface{}) {
 	ctx := context.Background()
-	defer recoverPanic(nil, "function registration", false)
+	defer recoverPanic(ctx, nil, "function registration")
 	if err := RegisterEventFunctionContext(ctx, path, fn); err != nil {
 		panic(fmt.Sprintf("unexpected error in RegisterEventFunctionContext: %v", err))
 	}
@@ -105,26 +132,122 @@ func RegisterCloudEventFunctionContext(ctx context.Context, path string, fn func
 	return registry.Default().RegisterCloudEvent(fn, registry.WithPath(path))
 }
 
+// RegisterCloudEventResponseFunctionContext registers fn as a CloudEvent function that may reply with
+// a response event. The response event is written using the same content mode (binary or structured)
+// as the incoming event. If fn returns a nil event, the response body is empty.
+func RegisterCloudEventResponseFunctionContext(ctx context.Context, path string, fn func(context.Context, cloudevents.Event) (*cloudevents.Event, error)) error {
+	return registry.Default().RegisterCloudEventResponse(fn, registry.WithPath(path))
+}
+
 // Start serves an HTTP server with registered function(s).
-func Start(port string) error {
-	return StartHostPort("", port)
+func Start(port string, opts ...ServerOption) error {
+	return StartHostPort("", port, opts...)
 }
 
 // StartHostPort serves an HTTP server with registered function(s) on the given host and port.
-func StartHostPort(hostname, port string) error {
-	server, err := initServer()
+// If port starts with "unix://", as in "unix:///tmp/fn.sock", the server
+// listens on the Unix domain socket at the given path instead and hostname is
+// ignored. The rest of the configuration is read from environment variables.
+func StartHostPort(hostname, port string, opts ...ServerOption) error {
+	cfg, err := configFromEnv()
+	if err != nil {
+		return err
+	}
+	cfg.Port = port
+	return start(cfg, func() (net.Listener, error) {
+		return netutil.Listen(hostname, port)
+	}, opts)
+}
+
+// StartWithConfig serves an HTTP server with registered function(s) as
+// configured by cfg, usually loaded with LoadConfig. Environment variables
+// are not read.
+func StartWithConfig(cfg *Config, opts ...ServerOption) error {
+	port := cfg.Port
+	if port == "" {
+		port = defaultPort
+	}
+	return start(cfg, func() (net.Listener, error) {
+		return netutil.Listen("", port)
+	}, opts)
+}
+
+// start serves the registered functions as configured by cfg on the listener
+// returned by listen, which is only called once the server is ready to accept
+// requests.
+func start(cfg *Config, listen func() (net.Listener, error), opts []ServerOption) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	server, err := initServerWithConfig(cfg, opts...)
+	if err != nil {
+		return err
+	}
+	serverCfg := newServerConfig(opts)
+	srv, err := newHTTPServer(server, serverCfg)
+	if err != nil {
+		return err
+	}
+	if err := runStartHooks(); err != nil {
+		return err
+	}
+	if serverCfg.listener != nil {
+		listen = func() (net.Listener, error) {
+			return serverCfg.listener, nil
+		}
+	}
+	l, err := listen()
 	if err != nil {
 		return err
 	}
-	return http.ListenAndServe(fmt.Sprintf("%s:%s", hostname, port), server)
+	if serverCfg.usesTLS() {
+		l = tls.NewListener(l, srv.TLSConfig)
+	}
+	return serve(srv, l)
 }
 
-func initServer() (*http.ServeMux, error) {
+// serve runs srv on l until it fails or the process receives SIGTERM or
+// SIGINT, in which case srv is shut down gracefully: in-flight invocations are
+// given up to shutdownTimeout to complete and serve returns nil.
+func serve(srv *http.Server, l net.Listener) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+
+	done := make(chan struct{})
+	defer close(done)
+	shutdown := make(chan error, 1)
+	go func() {
+		select {
+		case <-signals:
+		case <-done:
+			return
+		}
+		if hc := healthInstance; hc != nil {
+			hc.drain()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		shutdown <- srv.Shutdown(ctx)
+	}()
+
+	if err := srv.Serve(l); err != http.ErrServerClosed {
+		return err
+	}
+	return <-shutdown
+}
+
+// initServerWithConfig creates the server serving the registered functions as
+// configured by cfg and opts.
+func initServerWithConfig(cfg *Config, opts ...ServerOption) (*http.ServeMux, error) {
+	serverCfg := newServerConfig(opts)
 	server := http.NewServeMux()
+	resetConcurrencyLimiters()
+	activeConfig.Store(cfg)
 
-	// If FUNCTION_TARGET is set, only serve this target function at path "/".
+	// If a target is set, only serve this target function at path "/".
 	// If not set, serve all functions at the registered paths.
-	if target := os.Getenv("FUNCTION_TARGET"); len(target) > 0 {
+	if target := cfg.Target; len(target) > 0 {
 		var targetFn *registry.RegisteredFunction
 
 		fn, ok := registry.Default().GetRegisteredFunction(target)
@@ -142,67 +265,167 @@ func initServer() (*http.ServeMux, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to serve function %q: %v", target, err)
 		}
+		if serverCfg.accessLog || cfg.Debug {
+			h = logAccess(functionName(targetFn), h)
+		}
 		server.Handle("/", h)
+		if err := handleFrameworkEndpoints(server, nil); err != nil {
+			return nil, err
+		}
 		return server, nil
 	}
 
 	fns := registry.Default().GetAllFunctions()
 	for _, fn := range fns {
+		fmt.Printf("Serving function: %q\n", fn.Name)
 		h, err := wrapFunction(fn)
 		if err != nil {
 			return nil, fmt.Errorf("failed to serve function at path %q: %v", fn.Path, err)
 		}
+		if serverCfg.accessLog || cfg.Debug {
+			h = logAccess(functionName(fn), h)
+		}
 		server.Handle(fn.Path, h)
 	}
+	if err := handleFrameworkEndpoints(server, fns); err != nil {
+		return nil, err
+	}
 	return server, nil
 }
 
+// handleFrameworkEndpoints serves the endpoints provided by the framework
+// itself on server, next to the functions in fns.
+func handleFrameworkEndpoints(server *http.ServeMux, fns []*registry.RegisteredFunction) error {
+	if err := handleConcurrencyStatus(server, fns); err != nil {
+		return err
+	}
+	if hc := healthInstance; hc != nil {
+		if err := hc.handle(server, fns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func wrapFunction(fn *registry.RegisteredFunction) (http.Handler, error) {
-	// Check if we have a function resource set, and if so, log progress.
-	if os.Getenv("FUNCTION_TARGET") == "" {
-		fmt.Printf("Serving function: %q\n", fn.Name)
+	cfg := currentConfig()
+
+	handler, signatureType, err := wrapUserFunction(fn)
+	if err != nil {
+		return nil, err
 	}
+	if err := checkSignatureType(cfg.SignatureType, signatureType); err != nil {
+		return nil, err
+	}
+	handler = trackColdStart(handler)
+	handler = withTimeout(fn, handler)
+	handler = withConcurrencyLimit(fn, handler)
+	if t := telemetryInstance; t != nil {
+		handler = t.instrument(functionName(fn), signatureType, handler)
+	}
+	if c := logCaptureInstance; c != nil {
+		handler = c.track(handler)
+	}
+	handler = withFunctionInfo(fn, handler)
+	return handler, nil
+}
 
+// wrapUserFunction returns the handler serving fn along with the signature
+// type of fn.
+func wrapUserFunction(fn *registry.RegisteredFunction) (http.Handler, string, error) {
 	if fn.HTTPFn != nil {
 		handler, err := wrapHTTPFunction(fn.HTTPFn)
 		if err != nil {
-			return nil, fmt.Errorf("unexpected error in wrapHTTPFunction: %v", err)
+			return nil, "", fmt.Errorf("unexpected error in wrapHTTPFunction: %v", err)
 		}
-		return handler, nil
+		return handler, httpSignatureType, nil
 	} else if fn.CloudEventFn != nil {
 		handler, err := wrapCloudEventFunction(context.Background(), fn.CloudEventFn)
 		if err != nil {
-			return nil, fmt.Errorf("unexpected error in wrapCloudEventFunction: %v", err)
+			return nil, "", fmt.Errorf("unexpected error in wrapCloudEventFunction: %v", err)
 		}
-		return handler, nil
+		return handler, cloudEventSignatureType, nil
+	} else if fn.CloudEventResponseFn != nil {
+		handler, err := wrapCloudEventResponseFunction(context.Background(), fn.CloudEventResponseFn)
+		if err != nil {
+			return nil, "", fmt.Errorf("unexpected error in wrapCloudEventResponseFunction: %v", err)
+		}
+		return handler, cloudEventSignatureType, nil
 	} else if fn.EventFn != nil {
 		handler, err := wrapEventFunction(fn.EventFn)
 		if err != nil {
-			return nil, fmt.Errorf("unexpected error in wrapEventFunction: %v", err)
+			return nil, "", fmt.Errorf("unexpected error in wrapEventFunction: %v", err)
 		}
-		return handler, nil
+		return handler, eventSignatureType, nil
 	} else if fn.TypedFn != nil {
 		handler, err := wrapTypedFunction(fn.TypedFn)
 		if err != nil {
-			return nil, fmt.Errorf("unexpected error in wrapTypedFunction: %v", err)
+			return nil, "", fmt.Errorf("unexpected error in wrapTypedFunction: %v", err)
+		}
+		return handler, typedSignatureType, nil
+	}
+	return nil, "", fmt.Errorf("missing function entry in %v", fn)
+}
+
+// servingSignatureTypes lists, for each signature type a function can be
+// declared with, the signature types of the registered functions able to
+// serve it. Event functions convert incoming CloudEvents to background events
+// and CloudEvent functions convert incoming background events to CloudEvents,
+// so either can serve both kinds of events. Typed functions are served over
+// HTTP.
+var servingSignatureTypes = map[string][]string{
+	httpSignatureType:       {httpSignatureType, typedSignatureType},
+	eventSignatureType:      {eventSignatureType, cloudEventSignatureType},
+	cloudEventSignatureType: {cloudEventSignatureType, eventSignatureType},
+	typedSignatureType:      {typedSignatureType},
+}
+
+// registrationFuncs names the function registering a function of each
+// signature type.
+var registrationFuncs = map[string]string{
+	httpSignatureType:       "functions.HTTP",
+	eventSignatureType:      "funcframework.RegisterEventFunctionContext",
+	cloudEventSignatureType: "functions.CloudEvent",
+	typedSignatureType:      "functions.Typed",
+}
+
+// checkSignatureType returns an error if a function registered with the
+// signature type registered cannot serve requests of the declared signature
+// type, as set by FUNCTION_SIGNATURE_TYPE. Any registered function is served
+// if no signature type is declared.
+func checkSignatureType(declared, registered string) error {
+	if declared == "" {
+		return nil
+	}
+	for _, t := range servingSignatureTypes[declared] {
+		if t == registered {
+			return nil
 		}
-		return handler, nil
 	}
-	return nil, fmt.Errorf("missing function entry in %v", fn)
+	return fmt.Errorf("the function is registered with signature type %q, which cannot serve the declared signature type %q: register it with %s or declare signature type %q instead", registered, declared, registrationFuncs[declared], registered)
+}
+
+// functionName returns the name fn was registered with, or its path if it was
+// not registered declaratively.
+func functionName(fn *registry.RegisteredFunction) string {
+	if fn.Name != "" {
+		return fn.Name
+	}
+	return fn.Path
 }
 
 func wrapHTTPFunction(fn func(http.ResponseWriter, *http.Request)) (http.Handler, error) {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if os.Getenv("K_SERVICE") != "" {
+		if currentConfig().onGCF() {
 			// Force flush of logs after every function trigger when running on GCF.
 			defer fmt.Println()
 			defer fmt.Fprintln(os.Stderr)
 		}
-		r, cancel := setupRequestContext(r)
+		r, cancel := setupRequestContext(w, r)
 		if cancel != nil {
 			defer cancel()
 		}
-		defer recoverPanic(w, "user function execution", false)
+		defer recoverPanic(r.Context(), w, "user function execution")
 		fn(w, r)
 	}), nil
 }
@@ -213,59 +436,124 @@ func wrapEventFunction(fn interface{}) (http.Handler, error) {
 		return nil, err
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if os.Getenv("K_SERVICE") != "" {
+		if currentConfig().onGCF() {
 			// Force flush of logs after every function trigger when running on GCF.
 			defer fmt.Println()
 			defer fmt.Fprintln(os.Stderr)
 		}
-		r, cancel := setupRequestContext(r)
+		r, cancel := setupRequestContext(w, r)
 		if cancel != nil {
 			defer cancel()
 		}
 		if shouldConvertCloudEventToBackgroundRequest(r) {
-			if err := convertCloudEventToBackgroundRequest(r); err != nil {
-				writeHTTPErrorResponse(w, http.StatusBadRequest, crashStatus, fmt.Sprintf("error converting CloudEvent to Background Event: %v", err))
+			reqs, err := convertCloudEventToBackgroundRequests(r)
+			if err != nil {
+				writeHTTPErrorResponse(r.Context(), w, http.StatusBadRequest, crashStatus, fmt.Sprintf("error converting CloudEvent to Background Event: %v", err))
+				return
 			}
+			handleEventFunctions(w, reqs, fn)
+			return
 		}
 
 		handleEventFunction(w, r, fn)
 	}), nil
 }
 
+// handleEventFunctions runs fn once for each of reqs, in order, stopping at
+// the first invocation that writes an error response.
+func handleEventFunctions(w http.ResponseWriter, reqs []*http.Request, fn interface{}) {
+	sw := &statusRecorder{ResponseWriter: w}
+	for _, r := range reqs {
+		handleEventFunction(sw, r, fn)
+		if sw.status != 0 {
+			return
+		}
+	}
+}
+
+// statusRecorder records the status code and the number of body bytes written
+// to the wrapped http.ResponseWriter. status is zero until WriteHeader is
+// called.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	n, err := s.ResponseWriter.Write(b)
+	s.size += int64(n)
+	return n, err
+}
+
+func (s *statusRecorder) WriteHeader(statusCode int) {
+	if s.status == 0 {
+		s.status = statusCode
+	}
+	s.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Flush implements http.Flusher so that streaming HTTP functions keep working
+// when their response writer is wrapped.
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped http.ResponseWriter for use by
+// http.ResponseController.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
 func wrapTypedFunction(fn interface{}) (http.Handler, error) {
 	inputType, err := validateTypedFunction(fn)
 	if err != nil {
 		return nil, err
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if currentConfig().onGCF() {
+			// Force flush of logs after every function trigger when running on GCF.
+			defer fmt.Println()
+			defer fmt.Fprintln(os.Stderr)
+		}
+		r, cancel := setupRequestContext(w, r)
+		if cancel != nil {
+			defer cancel()
+		}
 		body, err := readHTTPRequestBody(r)
 		if err != nil {
-			writeHTTPErrorResponse(w, http.StatusBadRequest, crashStatus, fmt.Sprintf("%v", err))
+			writeHTTPErrorResponse(r.Context(), w, http.StatusBadRequest, crashStatus, fmt.Sprintf("%v", err))
 			return
 		}
 		argVal := inputType
 
 		if err := json.Unmarshal(body, argVal.Interface()); err != nil {
-			writeHTTPErrorResponse(w, http.StatusBadRequest, crashStatus, fmt.Sprintf("Error while converting input data. %s", err.Error()))
+			writeHTTPErrorResponse(r.Context(), w, http.StatusBadRequest, crashStatus, fmt.Sprintf("Error while converting input data. %s", err.Error()))
 			return
 		}
 
-		defer recoverPanic(w, "user function execution", false)
-		funcReturn := reflect.ValueOf(fn).Call([]reflect.Value{
-			argVal.Elem(),
-		})
+		defer recoverPanic(r.Context(), w, "user function execution")
+		args := []reflect.Value{argVal.Elem()}
+		if reflect.TypeOf(fn).NumIn() == 2 {
+			args = append([]reflect.Value{reflect.ValueOf(r.Context())}, args...)
+		}
+		funcReturn := reflect.ValueOf(fn).Call(args)
 
-		handleTypedReturn(w, funcReturn)
+		handleTypedReturn(r.Context(), w, funcReturn)
 	}), nil
 }
 
-func handleTypedReturn(w http.ResponseWriter, funcReturn []reflect.Value) {
+func handleTypedReturn(ctx context.Context, w http.ResponseWriter, funcReturn []reflect.Value) {
 	if len(funcReturn) == 0 {
 		return
 	}
 	errorVal := funcReturn[len(funcReturn)-1].Interface() // last return must be of type error
 	if errorVal != nil && reflect.TypeOf(errorVal).AssignableTo(errorType) {
-		writeHTTPErrorResponse(w, http.StatusInternalServerError, errorStatus, fmtFunctionError(errorVal))
+		recordFunctionError(ctx, errorVal)
+		reportFunctionError(ctx, errorVal)
+		writeErrorResponse(w, http.StatusInternalServerError, errorStatus, fmtFunctionError(errorVal))
 		return
 	}
 
@@ -278,7 +566,8 @@ func handleTypedReturn(w http.ResponseWriter, funcReturn []reflect.Value) {
 
 func validateTypedFunction(fn interface{}) (*reflect.Value, error) {
 	ft := reflect.TypeOf(fn)
-	if ft.NumIn() != 1 {
+	// The function may take the invocation context before its input.
+	if ft.NumIn() != 1 && !(ft.NumIn() == 2 && ft.In(0) == contextType) {
 		return nil, fmt.Errorf("expected function to have one parameters, found %d", ft.NumIn())
 	}
 	if ft.NumOut() > 2 {
@@ -287,7 +576,7 @@ func validateTypedFunction(fn interface{}) (*reflect.Value, error) {
 	if ft.NumOut() > 0 && !ft.Out(ft.NumOut()-1).AssignableTo(errorType) {
 		return nil, fmt.Errorf("expected last return type to be of error")
 	}
-	var inputType = reflect.New(ft.In(0))
+	var inputType = reflect.New(ft.In(ft.NumIn() - 1))
 	return &inputType, nil
 }
 
@@ -298,11 +587,19 @@ func wrapCloudEventFunction(ctx context.Context, fn func(context.Context, cloude
 	}
 
 	// Always log errors returned by the function to stderr
-	logErrFn := func(ctx context.Context, ce cloudevents.Event) error {
-		defer recoverPanic(nil, "user function execution", true)
-		err := fn(ctx, ce)
+	logErrFn := func(ctx context.Context, ce cloudevents.Event) (err error) {
+		// A panic must not reach the CloudEvents SDK, which would log it
+		// again. The error makes the SDK respond with a 500 status.
+		defer func() {
+			if r := recover(); r != nil {
+				err = handlePanic(ctx, nil, "user function execution", r)
+			}
+		}()
+		setEventSpanAttributes(ctx, ce.Type(), ce.ID())
+		err = fn(ctx, ce)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, fmtFunctionError(err))
+			reportFunctionError(ctx, err)
+			recordFunctionError(ctx, err)
 		}
 		return err
 	}
@@ -315,16 +612,48 @@ func wrapCloudEventFunction(ctx context.Context, fn func(context.Context, cloude
 	return convertBackgroundToCloudEvent(h), nil
 }
 
+func wrapCloudEventResponseFunction(ctx context.Context, fn func(context.Context, cloudevents.Event) (*cloudevents.Event, error)) (http.Handler, error) {
+	p, err := cloudevents.NewHTTP()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create protocol: %v", err)
+	}
+
+	// Always log errors returned by the function to stderr
+	logErrFn := func(ctx context.Context, ce cloudevents.Event) (resp *cloudevents.Event, err error) {
+		// As for CloudEvent functions without a response, the panic is
+		// returned as an error rather than propagated to the SDK.
+		defer func() {
+			if r := recover(); r != nil {
+				resp, err = nil, handlePanic(ctx, nil, "user function execution", r)
+			}
+		}()
+		setEventSpanAttributes(ctx, ce.Type(), ce.ID())
+		resp, err = fn(ctx, ce)
+		if err != nil {
+			reportFunctionError(ctx, err)
+			recordFunctionError(ctx, err)
+		}
+		return resp, err
+	}
+
+	h, err := cloudevents.NewHTTPReceiveHandler(ctx, p, logErrFn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create handler: %v", err)
+	}
+
+	return convertBackgroundToCloudEvent(h), nil
+}
+
 func handleEventFunction(w http.ResponseWriter, r *http.Request, fn interface{}) {
 	body, err := readHTTPRequestBody(r)
 	if err != nil {
-		writeHTTPErrorResponse(w, http.StatusBadRequest, crashStatus, fmt.Sprintf("%v", err))
+		writeHTTPErrorResponse(r.Context(), w, http.StatusBadRequest, crashStatus, fmt.Sprintf("%v", err))
 		return
 	}
 
 	// Background events have data and an associated metadata, so parse those and run if present.
 	if metadata, data, err := getBackgroundEvent(body, r.URL.Path); err != nil {
-		writeHTTPErrorResponse(w, http.StatusBadRequest, crashStatus, fmt.Sprintf("Error: %s, parsing background event: %s", err.Error(), string(body)))
+		writeHTTPErrorResponse(r.Context(), w, http.StatusBadRequest, crashStatus, fmt.Sprintf("Error: %s, parsing background event: %s", err.Error(), string(body)))
 		return
 	} else if data != nil && metadata != nil {
 		runBackgroundEvent(w, r, metadata, data, fn)
@@ -355,17 +684,19 @@ func runUserFunction(w http.ResponseWriter, r *http.Request, data []byte, fn int
 func runUserFunctionWithContext(ctx context.Context, w http.ResponseWriter, r *http.Request, data []byte, fn interface{}) {
 	argVal := reflect.New(reflect.TypeOf(fn).In(1))
 	if err := json.Unmarshal(data, argVal.Interface()); err != nil {
-		writeHTTPErrorResponse(w, http.StatusBadRequest, crashStatus, fmt.Sprintf("Error: %s, while converting event data: %s", err.Error(), string(data)))
+		writeHTTPErrorResponse(ctx, w, http.StatusBadRequest, crashStatus, fmt.Sprintf("Error: %s, while converting event data: %s", err.Error(), string(data)))
 		return
 	}
 
-	defer recoverPanic(w, "user function execution", false)
+	defer recoverPanic(ctx, w, "user function execution")
 	userFunErr := reflect.ValueOf(fn).Call([]reflect.Value{
 		reflect.ValueOf(ctx),
 		argVal.Elem(),
 	})
 	if userFunErr[0].Interface() != nil {
-		writeHTTPErrorResponse(w, http.StatusInternalServerError, errorStatus, fmtFunctionError(userFunErr[0].Interface()))
+		recordFunctionError(ctx, userFunErr[0].Interface())
+		reportFunctionError(ctx, userFunErr[0].Interface())
+		writeErrorResponse(w, http.StatusInternalServerError, errorStatus, fmtFunctionError(userFunErr[0].Interface()))
 		return
 	}
 }
@@ -379,16 +710,24 @@ func fmtFunctionError(err interface{}) string {
 	return formatted
 }
 
-func writeHTTPErrorResponse(w http.ResponseWriter, statusCode int, status, msg string) {
-	// Ensure logs end with a newline otherwise they are grouped incorrectly in SD.
+// writeHTTPErrorResponse logs msg as an error of the invocation with context
+// ctx and writes it as the response body with the given status code and
+// X-Google-Status value.
+func writeHTTPErrorResponse(ctx context.Context, w http.ResponseWriter, statusCode int, status, msg string) {
+	logFrameworkError(ctx, msg)
+	writeErrorResponse(w, statusCode, status, msg)
+}
+
+// writeErrorResponse writes msg as the response body with the given status
+// code and X-Google-Status value, without logging it.
+func writeErrorResponse(w http.ResponseWriter, statusCode int, status, msg string) {
 	if !strings.HasSuffix(msg, "\n") {
 		msg += "\n"
 	}
-	fmt.Fprint(os.Stderr, msg)
 
 	// Flush stdout and stderr when running on GCF. This must be done before writing
 	// the HTTP response in order for all logs to appear in GCF.
-	if os.Getenv("K_SERVICE") != "" {
+	if currentConfig().onGCF() {
 		fmt.Println()
 		fmt.Fprintln(os.Stderr)
 	}
@@ -398,23 +737,24 @@ func writeHTTPErrorResponse(w http.ResponseWriter, statusCode int, status, msg s
 	fmt.Fprint(w, msg)
 }
 
-func setupRequestContext(r *http.Request) (*http.Request, func()) {
+// setupRequestContext applies the timeout, if any, and the logging IDs of the
+// invocation to the context of r. The execution ID is returned to the client
+// in the Function-Execution-Id header of w.
+func setupRequestContext(w http.ResponseWriter, r *http.Request) (*http.Request, func()) {
 	r, cancel := setContextTimeoutIfRequested(r)
 	r = addLoggingIDsToRequest(r)
+	if executionID := ExecutionIDFromContext(r.Context()); executionID != "" {
+		w.Header().Set(executionIDHeader, executionID)
+	}
 	return r, cancel
 }
 
 // setContextTimeoutIfRequested replaces the request's context with a cancellation if requested
 func setContextTimeoutIfRequested(r *http.Request) (*http.Request, func()) {
-	timeoutStr := os.Getenv("CLOUD_RUN_TIMEOUT_SECONDS")
-	if timeoutStr == "" {
-		return r, nil
-	}
-	timeoutSecs, err := strconv.Atoi(timeoutStr)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Could not parse CLOUD_RUN_TIMEOUT_SECONDS as an integer value in seconds: %v\n", err)
+	timeout := currentConfig().Timeout
+	if timeout <= 0 {
 		return r, nil
 	}
-	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(timeoutSecs)*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
 	return r.WithContext(ctx), cancel
 }
@@ -517,6 +517,48 @@ func TestRegisterEventFunctionContext(t *testing.T) {
 				"ce-datacontenttype": "application/json",
 			},
 		},
+		{
+			name: "structured cloudevent",
+			path: "/TestRegisterEventFunctionContext_structured_cloudevent",
+			body: []byte(`{
+				"specversion": "1.0",
+				"type": "google.cloud.storage.object.v1.finalized",
+				"source": "//storage.googleapis.com/projects/_/buckets/some-bucket",
+				"subject": "objects/folder/Test.cs",
+				"id": "aaaaaa-1111-bbbb-2222-cccccccccccc",
+				"time": "2020-09-29T11:32:00.000Z",
+				"datacontenttype": "application/json",
+				"data": {"name": "folder/Test.cs"}
+			}`),
+			fn: func(c context.Context, gotData map[string]interface{}) error {
+				if got := gotData["name"]; got != "folder/Test.cs" {
+					return fmt.Errorf("TestEventFunction(structured cloudevent): got name=%v, want name=\"folder/Test.cs\"", got)
+				}
+				return nil
+			},
+			status: http.StatusOK,
+			header: "",
+			ceHeaders: map[string]string{
+				"Content-Type": "application/cloudevents+json",
+			},
+		},
+		{
+			name: "unmapped cloudevent",
+			path: "/TestRegisterEventFunctionContext_unmapped_cloudevent",
+			body: []byte(`{"id": 12345,"name": "custom"}`),
+			fn: func(c context.Context, s customStruct) error {
+				return fmt.Errorf("TestEventFunction(unmapped cloudevent): function should not be called")
+			},
+			status:     http.StatusBadRequest,
+			header:     "crash",
+			wantStderr: `CloudEvent type "com.example.unknown" has no background event equivalent`,
+			ceHeaders: map[string]string{
+				"ce-specversion": "1.0",
+				"ce-type":        "com.example.unknown",
+				"ce-source":      "//example.com/source",
+				"ce-id":          "1234",
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -861,6 +903,172 @@ func TestRegisterCloudEventFunctionContext(t *testing.T) {
 	}
 }
 
+func TestRegisterCloudEventResponseFunctionContext(t *testing.T) {
+	cloudeventsJSON := []byte(`{
+		"specversion" : "1.0",
+		"type" : "com.github.pull.create",
+		"source" : "https://github.com/cloudevents/spec/pull",
+		"subject" : "123",
+		"id" : "A234-1234-1234",
+		"time" : "2018-04-05T17:31:00Z",
+		"datacontenttype" : "application/json",
+		"data" : {"number": 123}
+	}`)
+	reply := func(ctx context.Context, e cloudevents.Event) (*cloudevents.Event, error) {
+		resp := cloudevents.NewEvent()
+		resp.SetID("reply-" + e.ID())
+		resp.SetSource("//test/reply")
+		resp.SetType(e.Type() + ".reply")
+		if err := resp.SetData(cloudevents.ApplicationJSON, map[string]string{"reply": "ok"}); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	}
+
+	var tests = []struct {
+		name            string
+		path            string
+		body            []byte
+		fn              func(context.Context, cloudevents.Event) (*cloudevents.Event, error)
+		ceHeaders       map[string]string
+		status          int
+		wantContentType string
+		wantCEHeaders   map[string]string
+		wantResp        string
+		wantStderr      string
+	}{
+		{
+			name: "binary request gets binary reply",
+			path: "/TestRegisterCloudEventResponseFunctionContext_binary",
+			body: []byte(`{"number": 123}`),
+			fn:   reply,
+			ceHeaders: map[string]string{
+				"ce-specversion": "1.0",
+				"ce-type":        "com.github.pull.create",
+				"ce-source":      "https://github.com/cloudevents/spec/pull",
+				"ce-id":          "A234-1234-1234",
+				"Content-Type":   "application/json",
+			},
+			status:          http.StatusOK,
+			wantContentType: "application/json",
+			wantCEHeaders: map[string]string{
+				"ce-id":   "reply-A234-1234-1234",
+				"ce-type": "com.github.pull.create.reply",
+			},
+			wantResp: `{"reply":"ok"}`,
+		},
+		{
+			name: "structured request gets structured reply",
+			path: "/TestRegisterCloudEventResponseFunctionContext_structured",
+			body: cloudeventsJSON,
+			fn:   reply,
+			ceHeaders: map[string]string{
+				"Content-Type": "application/cloudevents+json",
+			},
+			status:          http.StatusOK,
+			wantContentType: "application/cloudevents+json",
+			wantResp:        `"id":"reply-A234-1234-1234"`,
+		},
+		{
+			name: "nil reply",
+			path: "/TestRegisterCloudEventResponseFunctionContext_nil",
+			body: cloudeventsJSON,
+			fn: func(ctx context.Context, e cloudevents.Event) (*cloudevents.Event, error) {
+				return nil, nil
+			},
+			ceHeaders: map[string]string{
+				"Content-Type": "application/cloudevents+json",
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "error returns 500",
+			path: "/TestRegisterCloudEventResponseFunctionContext_error",
+			body: cloudeventsJSON,
+			fn: func(ctx context.Context, e cloudevents.Event) (*cloudevents.Event, error) {
+				return nil, fmt.Errorf("error for test")
+			},
+			ceHeaders: map[string]string{
+				"Content-Type": "application/cloudevents+json",
+			},
+			status:     http.StatusInternalServerError,
+			wantStderr: "error for test",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			defer cleanup()
+
+			if err := RegisterCloudEventResponseFunctionContext(context.Background(), tc.path, tc.fn); err != nil {
+				t.Fatalf("RegisterCloudEventResponseFunctionContext(): %v", err)
+			}
+
+			origStderrPipe := os.Stderr
+			r, w, _ := os.Pipe()
+			os.Stderr = w
+			defer func() { os.Stderr = origStderrPipe }()
+
+			server, err := initServer()
+			if err != nil {
+				t.Fatalf("initServer(): %v", err)
+			}
+			srv := httptest.NewServer(server)
+			defer srv.Close()
+
+			req, err := http.NewRequest("POST", srv.URL+tc.path, bytes.NewBuffer(tc.body))
+			if err != nil {
+				t.Fatalf("error creating HTTP request for test: %v", err)
+			}
+			for k, v := range tc.ceHeaders {
+				req.Header.Add(k, v)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("client.Do(%s): %v", tc.name, err)
+			}
+
+			if err := w.Close(); err != nil {
+				t.Fatalf("failed to close stderr write pipe: %v", err)
+			}
+			stderr, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Errorf("failed to read stderr read pipe: %v", err)
+			}
+			if err := r.Close(); err != nil {
+				t.Fatalf("failed to close stderr read pipe: %v", err)
+			}
+
+			if !strings.Contains(string(stderr), tc.wantStderr) {
+				t.Errorf("stderr mismatch, got: %q, must contain: %q", string(stderr), tc.wantStderr)
+			}
+
+			gotBody, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("unable to read got request body: %v", err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Errorf("TestCloudEventResponseFunction(%s): response status = %v, want %v, %q.", tc.name, resp.StatusCode, tc.status, string(gotBody))
+			}
+			if tc.wantResp == "" && len(gotBody) != 0 {
+				t.Errorf("TestCloudEventResponseFunction(%s): response body = %q, want empty", tc.name, gotBody)
+			}
+			if !strings.Contains(string(gotBody), tc.wantResp) {
+				t.Errorf("TestCloudEventResponseFunction(%s): response body = %q, must contain %q", tc.name, gotBody, tc.wantResp)
+			}
+			if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, tc.wantContentType) {
+				t.Errorf("TestCloudEventResponseFunction(%s): Content-Type = %q, want %q", tc.name, got, tc.wantContentType)
+			}
+			for k, v := range tc.wantCEHeaders {
+				if got := resp.Header.Get(k); got != v {
+					t.Errorf("TestCloudEventResponseFunction(%s): header %s = %q, want %q", tc.name, k, got, v)
+				}
+			}
+		})
+	}
+}
+
 func TestDeclarativeFunctionHTTP(t *testing.T) {
 	defer cleanup()
 	funcName := "httpfunc"
@@ -940,10 +1148,76 @@ func TestFunctionsNotRegisteredError(t *testing.T) {
 	}
 }
 
+func TestDeclaredSignatureType(t *testing.T) {
+	tcs := []struct {
+		name          string
+		register      func()
+		signatureType string
+		wantErr       string
+	}{
+		{
+			name:          "http function",
+			register:      func() { functions.HTTP("fn", func(w http.ResponseWriter, r *http.Request) {}) },
+			signatureType: "http",
+		},
+		{
+			name:          "typed function served over http",
+			register:      func() { functions.Typed("fn", func(in string) (string, error) { return in, nil }) },
+			signatureType: "http",
+		},
+		{
+			name:          "event function served as cloudevent",
+			register:      func() { RegisterEventFunctionContext(context.Background(), "/fn", dummyEvent) },
+			signatureType: "cloudevent",
+		},
+		{
+			name:          "cloudevent function served as event",
+			register:      func() { functions.CloudEvent("fn", dummyCloudEvent) },
+			signatureType: "event",
+		},
+		{
+			name:          "cloudevent function served as http",
+			register:      func() { functions.CloudEvent("fn", dummyCloudEvent) },
+			signatureType: "http",
+			wantErr:       `the function is registered with signature type "cloudevent", which cannot serve the declared signature type "http": register it with functions.HTTP or declare signature type "cloudevent" instead`,
+		},
+		{
+			name:          "http function served as typed",
+			register:      func() { functions.HTTP("fn", func(w http.ResponseWriter, r *http.Request) {}) },
+			signatureType: "typed",
+			wantErr:       `register it with functions.Typed`,
+		},
+		{
+			name:          "event function served as http",
+			register:      func() { RegisterEventFunctionContext(context.Background(), "/fn", dummyEvent) },
+			signatureType: "http",
+			wantErr:       `registered with signature type "event"`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer cleanup()
+			tc.register()
+			_, err := initServerWithConfig(&Config{Target: "fn", SignatureType: tc.signatureType})
+			if tc.wantErr == "" && err != nil {
+				t.Errorf("initServerWithConfig(): %v", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Errorf("initServerWithConfig() error = %v, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
 func dummyCloudEvent(ctx context.Context, e cloudevents.Event) error {
 	return nil
 }
 
+func dummyEvent(ctx context.Context, data map[string]interface{}) error {
+	return nil
+}
+
 func TestServeMultipleFunctions(t *testing.T) {
 	defer cleanup()
 	fns := []struct {
@@ -1019,6 +1293,7 @@ func TestHTTPRequestTimeout(t *testing.T) {
 		wantDeadline      bool
 		waitForExpiration bool
 		timeout           string
+		wantConfigErr     bool
 	}{
 		{
 			name:              "deadline not requested",
@@ -1031,6 +1306,7 @@ func TestHTTPRequestTimeout(t *testing.T) {
 			wantDeadline:      false,
 			waitForExpiration: false,
 			timeout:           "aaa",
+			wantConfigErr:     true,
 		},
 		{
 			name:              "very long deadline",
@@ -1067,6 +1343,12 @@ func TestHTTPRequestTimeout(t *testing.T) {
 				return nil
 			})
 			server, err := initServer()
+			if tc.wantConfigErr {
+				if err == nil {
+					t.Errorf("initServer() succeeded with %s=%q, want an error", timeoutEnvVar, tc.timeout)
+				}
+				return
+			}
 			if err != nil {
 				t.Fatalf("initServer(): %v", err)
 			}
@@ -1116,7 +1398,120 @@ func TestHTTPRequestTimeout(t *testing.T) {
 	}
 }
 
+func TestRequestContextLoggingIDs(t *testing.T) {
+	defer cleanup()
+
+	type input struct{}
+	type output struct {
+		ExecutionID string
+		TraceID     string
+	}
+	idsFromContext := func(ctx context.Context) output {
+		return output{ExecutionID: ExecutionIDFromContext(ctx), TraceID: TraceIDFromContext(ctx)}
+	}
+
+	var got output
+	functions.HTTP("http", func(w http.ResponseWriter, r *http.Request) {
+		got = idsFromContext(r.Context())
+	})
+	if err := RegisterEventFunctionContext(context.Background(), "/event", func(ctx context.Context, data map[string]interface{}) error {
+		got = idsFromContext(ctx)
+		return nil
+	}); err != nil {
+		t.Fatalf("RegisterEventFunctionContext(): %v", err)
+	}
+	functions.CloudEvent("cloudevent", func(ctx context.Context, e event.Event) error {
+		got = idsFromContext(ctx)
+		return nil
+	})
+	functions.Typed("typed", func(ctx context.Context, in input) (output, error) {
+		got = idsFromContext(ctx)
+		return got, nil
+	})
+
+	server, err := initServer()
+	if err != nil {
+		t.Fatalf("initServer(): %v", err)
+	}
+
+	tcs := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		body    string
+	}{
+		{
+			name: "http",
+			path: "/http",
+		},
+		{
+			name: "event",
+			path: "/event",
+			body: `{}`,
+		},
+		{
+			name: "cloudevent",
+			path: "/cloudevent",
+			headers: map[string]string{
+				"ce-specversion": "1.0",
+				"ce-type":        "com.example.test",
+				"ce-source":      "//example.com/source",
+				"ce-id":          "A234-1234-1234",
+				"Content-Type":   "application/json",
+			},
+			body: `{}`,
+		},
+		{
+			name: "typed",
+			path: "/typed",
+			body: `{}`,
+		},
+	}
+
+	for _, tc := range tcs {
+		for _, executionID := range []string{"", "provided-id"} {
+			t.Run(fmt.Sprintf("%s with execution ID %q", tc.name, executionID), func(t *testing.T) {
+				got = output{}
+				req := httptest.NewRequest("POST", tc.path, bytes.NewBufferString(tc.body))
+				for k, v := range tc.headers {
+					req.Header.Set(k, v)
+				}
+				req.Header.Set("X-Cloud-Trace-Context", "0123456789abcdef/1;o=1")
+				if executionID != "" {
+					req.Header.Set("Function-Execution-Id", executionID)
+				}
+				rec := httptest.NewRecorder()
+				server.ServeHTTP(rec, req)
+
+				if rec.Code != http.StatusOK {
+					t.Fatalf("response status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body)
+				}
+				if got.TraceID != "0123456789abcdef" {
+					t.Errorf("TraceIDFromContext() = %q, want %q", got.TraceID, "0123456789abcdef")
+				}
+				if got.ExecutionID == "" || (executionID != "" && got.ExecutionID != executionID) {
+					t.Errorf("ExecutionIDFromContext() = %q, want %q or a generated ID", got.ExecutionID, executionID)
+				}
+				if header := rec.Header().Get("Function-Execution-Id"); header != got.ExecutionID {
+					t.Errorf("Function-Execution-Id response header = %q, want %q", header, got.ExecutionID)
+				}
+			})
+		}
+	}
+}
+
+// initServer creates the server with the configuration set by environment
+// variables.
+func initServer(opts ...ServerOption) (*http.ServeMux, error) {
+	cfg, err := configFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return initServerWithConfig(cfg, opts...)
+}
+
 func cleanup() {
 	os.Unsetenv("FUNCTION_TARGET")
 	registry.Default().Reset()
+	activeConfig.Store(nil)
 }
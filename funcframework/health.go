@@ -0,0 +1,203 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/internal/registry"
+)
+
+const (
+	defaultHealthCheckPath = "/_ah/health"
+	readinessProbeSuffix   = "/ready"
+	startupProbeSuffix     = "/startup"
+
+	healthStatusOK       = "ok"
+	healthStatusStarting = "starting"
+	healthStatusNotReady = "not ready"
+	healthStatusDraining = "draining"
+)
+
+// healthInstance is set by EnableHealthChecks. If nil, no probe endpoints are
+// served.
+var healthInstance *health
+
+// HealthCheckOption configures the probe endpoints enabled by
+// EnableHealthChecks.
+type HealthCheckOption func(*healthConfig)
+
+type healthConfig struct {
+	path       string
+	checks     []readinessCheck
+	drainDelay time.Duration
+}
+
+type readinessCheck struct {
+	name  string
+	check func(context.Context) error
+}
+
+// WithHealthCheckPath sets the path of the liveness probe endpoint, which the
+// readiness and startup probe paths are derived from. Defaults to
+// "/_ah/health".
+func WithHealthCheckPath(path string) HealthCheckOption {
+	return func(c *healthConfig) {
+		c.path = path
+	}
+}
+
+// WithReadinessCheck adds a check that must succeed for the function to be
+// reported as ready, for example one verifying that a connection pool or cache
+// set up by the function has been initialized. name identifies the check in
+// probe responses. Checks are run on every readiness probe, with the context
+// of the probe request.
+func WithReadinessCheck(name string, check func(context.Context) error) HealthCheckOption {
+	return func(c *healthConfig) {
+		c.checks = append(c.checks, readinessCheck{name: name, check: check})
+	}
+}
+
+// WithDrainDelay sets how long the server keeps accepting requests, while
+// reporting that it is draining, after receiving SIGTERM and before shutting
+// down. This gives load balancers polling the readiness probe time to stop
+// routing requests to the instance. Defaults to 0.
+func WithDrainDelay(d time.Duration) HealthCheckOption {
+	return func(c *healthConfig) {
+		c.drainDelay = d
+	}
+}
+
+// EnableHealthChecks serves built-in probe endpoints alongside the functions,
+// so that liveness, readiness and startup probes do not have to invoke a
+// function:
+//
+//   - "/_ah/health" (the liveness probe) responds with 200 OK as long as the
+//     server is running.
+//   - "/_ah/health/ready" (the readiness probe) responds with 200 OK once all
+//     the checks added with WithReadinessCheck succeed, and with 503 Service
+//     Unavailable otherwise or once the server is draining.
+//   - "/_ah/health/startup" (the startup probe) responds with 200 OK once the
+//     readiness checks have succeeded at least once, and with 503 Service
+//     Unavailable before.
+//
+// Probe responses are JSON objects with the status of the server and the
+// errors of any failing readiness check. The probe paths must not collide with
+// the paths of the registered functions.
+//
+// EnableHealthChecks must be called before Start.
+func EnableHealthChecks(opts ...HealthCheckOption) error {
+	cfg := healthConfig{path: defaultHealthCheckPath}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if !strings.HasPrefix(cfg.path, "/") || cfg.path == "/" || strings.HasSuffix(cfg.path, "/") {
+		return fmt.Errorf("invalid health check path %q: must start with '/' and must not end with '/'", cfg.path)
+	}
+	healthInstance = &health{cfg: cfg}
+	return nil
+}
+
+// health serves the probe endpoints enabled by EnableHealthChecks.
+type health struct {
+	cfg      healthConfig
+	started  atomic.Bool
+	draining atomic.Bool
+}
+
+// healthStatus is the response body of the probe endpoints.
+type healthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// handle serves the probe endpoints on server, after checking that they do
+// not collide with the functions in fns.
+func (hc *health) handle(server *http.ServeMux, fns []*registry.RegisteredFunction) error {
+	for _, fn := range fns {
+		if fn.Path == hc.cfg.path || strings.HasPrefix(fn.Path, hc.cfg.path+"/") {
+			return fmt.Errorf("function path %q collides with health check path %q", fn.Path, hc.cfg.path)
+		}
+	}
+	server.HandleFunc(hc.cfg.path, hc.serveLiveness)
+	server.HandleFunc(hc.cfg.path+readinessProbeSuffix, hc.serveReadiness)
+	server.HandleFunc(hc.cfg.path+startupProbeSuffix, hc.serveStartup)
+	return nil
+}
+
+func (hc *health) serveLiveness(w http.ResponseWriter, r *http.Request) {
+	status := healthStatusOK
+	if hc.draining.Load() {
+		status = healthStatusDraining
+	}
+	writeHealthStatus(w, http.StatusOK, healthStatus{Status: status})
+}
+
+func (hc *health) serveReadiness(w http.ResponseWriter, r *http.Request) {
+	if hc.draining.Load() {
+		writeHealthStatus(w, http.StatusServiceUnavailable, healthStatus{Status: healthStatusDraining})
+		return
+	}
+	if failures := hc.runChecks(r.Context()); len(failures) > 0 {
+		writeHealthStatus(w, http.StatusServiceUnavailable, healthStatus{Status: healthStatusNotReady, Checks: failures})
+		return
+	}
+	writeHealthStatus(w, http.StatusOK, healthStatus{Status: healthStatusOK})
+}
+
+func (hc *health) serveStartup(w http.ResponseWriter, r *http.Request) {
+	if !hc.started.Load() {
+		if failures := hc.runChecks(r.Context()); len(failures) > 0 {
+			writeHealthStatus(w, http.StatusServiceUnavailable, healthStatus{Status: healthStatusStarting, Checks: failures})
+			return
+		}
+	}
+	writeHealthStatus(w, http.StatusOK, healthStatus{Status: healthStatusOK})
+}
+
+// runChecks runs the readiness checks and returns the errors of the failing
+// ones by name. The server is considered started once they all succeed.
+func (hc *health) runChecks(ctx context.Context) map[string]string {
+	failures := map[string]string{}
+	for _, c := range hc.cfg.checks {
+		if err := c.check(ctx); err != nil {
+			failures[c.name] = err.Error()
+		}
+	}
+	if len(failures) == 0 {
+		hc.started.Store(true)
+	}
+	return failures
+}
+
+// drain makes the readiness probe fail from now on and waits for the
+// configured drain delay.
+func (hc *health) drain() {
+	hc.draining.Store(true)
+	time.Sleep(hc.cfg.drainDelay)
+}
+
+func writeHealthStatus(w http.ResponseWriter, statusCode int, status healthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,194 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHealthChecks(t *testing.T) {
+	defer cleanup()
+	defer func() { healthInstance = nil }()
+
+	ready := errors.New("cache not loaded")
+	if err := EnableHealthChecks(WithReadinessCheck("cache", func(ctx context.Context) error {
+		return ready
+	})); err != nil {
+		t.Fatalf("EnableHealthChecks(): %v", err)
+	}
+	os.Setenv("FUNCTION_TARGET", "fn")
+	functions.HTTP("fn", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Hello World!")
+	})
+	server, err := initServer()
+	if err != nil {
+		t.Fatalf("initServer(): %v", err)
+	}
+
+	probe := func(path string) (int, healthStatus) {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		var status healthStatus
+		if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+			t.Fatalf("decoding %s response: %v", path, err)
+		}
+		return rec.Code, status
+	}
+
+	steps := []struct {
+		name string
+		do   func()
+		path string
+		want int
+		body healthStatus
+	}{
+		{
+			name: "live while starting",
+			path: "/_ah/health",
+			want: http.StatusOK,
+			body: healthStatus{Status: healthStatusOK},
+		},
+		{
+			name: "not started",
+			path: "/_ah/health/startup",
+			want: http.StatusServiceUnavailable,
+			body: healthStatus{Status: healthStatusStarting, Checks: map[string]string{"cache": "cache not loaded"}},
+		},
+		{
+			name: "not ready",
+			path: "/_ah/health/ready",
+			want: http.StatusServiceUnavailable,
+			body: healthStatus{Status: healthStatusNotReady, Checks: map[string]string{"cache": "cache not loaded"}},
+		},
+		{
+			name: "ready",
+			do:   func() { ready = nil },
+			path: "/_ah/health/ready",
+			want: http.StatusOK,
+			body: healthStatus{Status: healthStatusOK},
+		},
+		{
+			name: "started once ready",
+			do:   func() { ready = errors.New("cache evicted") },
+			path: "/_ah/health/startup",
+			want: http.StatusOK,
+			body: healthStatus{Status: healthStatusOK},
+		},
+		{
+			name: "draining",
+			do:   func() { ready = nil; healthInstance.drain() },
+			path: "/_ah/health/ready",
+			want: http.StatusServiceUnavailable,
+			body: healthStatus{Status: healthStatusDraining},
+		},
+		{
+			name: "live while draining",
+			path: "/_ah/health",
+			want: http.StatusOK,
+			body: healthStatus{Status: healthStatusDraining},
+		},
+	}
+	for _, s := range steps {
+		if s.do != nil {
+			s.do()
+		}
+		code, body := probe(s.path)
+		if code != s.want {
+			t.Errorf("%s: %s status = %v, want %v", s.name, s.path, code, s.want)
+		}
+		if diff := cmp.Diff(s.body, body); diff != "" {
+			t.Errorf("%s: %s body mismatch (-want +got):\n%s", s.name, s.path, diff)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if got := rec.Body.String(); got != "Hello World!" {
+		t.Errorf("function response = %q, want %q", got, "Hello World!")
+	}
+}
+
+func TestHealthCheckPath(t *testing.T) {
+	tcs := []struct {
+		name    string
+		path    string
+		fnPath  string
+		wantErr string
+	}{
+		{
+			name:   "custom path",
+			path:   "/healthz",
+			fnPath: "/fn",
+		},
+		{
+			name:    "invalid path",
+			path:    "/healthz/",
+			wantErr: "invalid health check path",
+		},
+		{
+			name:    "function at the health check path",
+			path:    "/healthz",
+			fnPath:  "/healthz",
+			wantErr: "collides with health check path",
+		},
+		{
+			name:    "function under the health check path",
+			path:    "/healthz",
+			fnPath:  "/healthz/ready",
+			wantErr: "collides with health check path",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer cleanup()
+			defer func() { healthInstance = nil }()
+
+			err := EnableHealthChecks(WithHealthCheckPath(tc.path))
+			if err == nil {
+				if err := RegisterHTTPFunctionContext(context.Background(), tc.fnPath, func(w http.ResponseWriter, r *http.Request) {}); err != nil {
+					t.Fatalf("RegisterHTTPFunctionContext(): %v", err)
+				}
+				var server *http.ServeMux
+				server, err = initServer()
+				if err == nil {
+					rec := httptest.NewRecorder()
+					server.ServeHTTP(rec, httptest.NewRequest("GET", tc.path+readinessProbeSuffix, nil))
+					if rec.Code != http.StatusOK {
+						t.Errorf("%s status = %v, want %v", tc.path+readinessProbeSuffix, rec.Code, http.StatusOK)
+					}
+				}
+			}
+
+			if tc.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Errorf("error = %v, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
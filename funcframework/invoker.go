@@ -0,0 +1,27 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import "github.com/GoogleCloudPlatform/functions-framework-go/internal/invoker"
+
+// The fftest package invokes functions through the same wrapping as the
+// server, and the ffevent command converts events the same way.
+func init() {
+	invoker.WrapFunction = wrapFunction
+	invoker.ContextWithLogOutput = contextWithLogOutput
+	invoker.BackgroundEventToCloudEvent = func(event []byte) ([]byte, error) {
+		return convertBackgroundEventToCloudEvent(event, "/")
+	}
+}
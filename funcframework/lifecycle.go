@@ -0,0 +1,99 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const defaultStartupTimeout = time.Minute
+
+var coldStartContextKey contextKey = "coldStart"
+
+var (
+	// startHooks are the hooks added by OnStart, in the order they were
+	// added.
+	startHooks []func(context.Context) error
+	// startupTimeout is set by SetStartupTimeout.
+	startupTimeout = defaultStartupTimeout
+	// invoked is set once the first invocation of the instance starts.
+	invoked atomic.Bool
+)
+
+// OnStart adds a hook run by Start after the functions have been registered
+// and before the server starts listening, for example to open connections or
+// load data used by the functions. Hooks run one at a time, in the order they
+// were added. The first error returned by a hook is returned by Start, which
+// then does not serve the functions.
+//
+// The context passed to hooks is cancelled once the startup timeout, set with
+// SetStartupTimeout, has elapsed. If the hooks have not returned by then,
+// Start returns an error.
+func OnStart(hook func(ctx context.Context) error) {
+	startHooks = append(startHooks, hook)
+}
+
+// SetStartupTimeout sets how long the hooks added by OnStart may run for in
+// total. Defaults to one minute.
+func SetStartupTimeout(d time.Duration) {
+	startupTimeout = d
+}
+
+// runStartHooks runs the hooks added by OnStart within the startup timeout.
+func runStartHooks() error {
+	if len(startHooks) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
+	defer cancel()
+	for i, hook := range startHooks {
+		done := make(chan error, 1)
+		go func() {
+			done <- hook(ctx)
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				return fmt.Errorf("start hook %d failed: %w", i, err)
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("start hooks did not complete within %v", startupTimeout)
+		}
+	}
+	return nil
+}
+
+// IsColdStart reports whether ctx is the context of the first invocation
+// served by this instance, which typically takes longer than later ones as
+// the state initialized lazily by the function is not set up yet.
+func IsColdStart(ctx context.Context) bool {
+	cold, _ := ctx.Value(coldStartContextKey).(bool)
+	return cold
+}
+
+// trackColdStart wraps h so that the first invocation it serves, across all
+// the functions of the instance, is flagged as a cold start in its context.
+func trackColdStart(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !invoked.Swap(true) {
+			r = r.WithContext(context.WithValue(r.Context(), coldStartContextKey, true))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
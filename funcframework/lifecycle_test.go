@@ -0,0 +1,130 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRunStartHooks(t *testing.T) {
+	errHook := errors.New("connection refused")
+	tcs := []struct {
+		name      string
+		hooks     func(ran *[]int) []func(context.Context) error
+		wantRan   []int
+		wantErr   error
+		wantInErr string
+	}{
+		{
+			name: "all succeed",
+			hooks: func(ran *[]int) []func(context.Context) error {
+				return []func(context.Context) error{
+					func(ctx context.Context) error { *ran = append(*ran, 0); return nil },
+					func(ctx context.Context) error { *ran = append(*ran, 1); return nil },
+				}
+			},
+			wantRan: []int{0, 1},
+		},
+		{
+			name: "stops at the first error",
+			hooks: func(ran *[]int) []func(context.Context) error {
+				return []func(context.Context) error{
+					func(ctx context.Context) error { *ran = append(*ran, 0); return errHook },
+					func(ctx context.Context) error { *ran = append(*ran, 1); return nil },
+				}
+			},
+			wantRan: []int{0},
+			wantErr: errHook,
+		},
+		{
+			name: "times out",
+			hooks: func(ran *[]int) []func(context.Context) error {
+				return []func(context.Context) error{
+					func(ctx context.Context) error { select {} },
+				}
+			},
+			wantInErr: "did not complete within",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				startHooks = nil
+				startupTimeout = defaultStartupTimeout
+			}()
+			SetStartupTimeout(100 * time.Millisecond)
+
+			var ran []int
+			for _, hook := range tc.hooks(&ran) {
+				OnStart(hook)
+			}
+			err := runStartHooks()
+
+			if diff := cmp.Diff(tc.wantRan, ran); diff != "" {
+				t.Errorf("hooks run mismatch (-want +got):\n%s", diff)
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("runStartHooks() = %v, want %v", err, tc.wantErr)
+			}
+			if tc.wantInErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantInErr)) {
+				t.Errorf("runStartHooks() = %v, want it to contain %q", err, tc.wantInErr)
+			}
+			if tc.wantErr == nil && tc.wantInErr == "" && err != nil {
+				t.Errorf("runStartHooks() = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestIsColdStart(t *testing.T) {
+	defer cleanup()
+	defer invoked.Store(false)
+	invoked.Store(false)
+
+	var got []bool
+	functions.HTTP("http", func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, IsColdStart(r.Context()))
+	})
+	functions.CloudEvent("cloudevent", func(ctx context.Context, e cloudevents.Event) error {
+		got = append(got, IsColdStart(ctx))
+		return nil
+	})
+	server, err := initServer()
+	if err != nil {
+		t.Fatalf("initServer(): %v", err)
+	}
+
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/http", nil))
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/http", nil))
+	req := httptest.NewRequest("POST", "/cloudevent", bytes.NewBufferString(`{"specversion":"1.0","type":"test","source":"test","id":"1"}`))
+	req.Header.Set("Content-Type", "application/cloudevents+json")
+	server.ServeHTTP(httptest.NewRecorder(), req)
+
+	if diff := cmp.Diff([]bool{true, false, false}, got); diff != "" {
+		t.Errorf("IsColdStart() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -10,49 +10,118 @@ import (
 	"net/http"
 	"os"
 	"regexp"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+// executionIDHeader carries the execution ID of an invocation in requests and
+// responses.
+const executionIDHeader = "Function-Execution-Id"
+
 var (
-	loggingIDsContextKey    contextKey = "loggingIDs"
-	validXCloudTraceContext            = regexp.MustCompile(
+	loggingIDsContextKey           contextKey = "loggingIDs"
+	cloudEventExtensionsContextKey contextKey = "cloudEventExtensions"
+	httpRequestContextKey          contextKey = "httpRequest"
+	logOutputContextKey            contextKey = "logOutput"
+	validXCloudTraceContext                   = regexp.MustCompile(
 		// Matches on "TRACE_ID"
 		`([a-f\d]+)?` +
 			// Matches on "/SPAN_ID"
 			`(?:/([a-f\d]+))?` +
 			// Matches on ";0=TRACE_TRUE"
 			`(?:;o=(\d))?`)
+	// Matches on "VERSION-TRACE_ID-SPAN_ID-TRACE_FLAGS", see
+	// https://www.w3.org/TR/trace-context/#traceparent-header-field-values
+	validTraceparent = regexp.MustCompile(`^([\da-f]{2})-([\da-f]{32})-([\da-f]{16})-([\da-f]{2})`)
 )
 
 type loggingIDs struct {
-	trace       string
-	spanID      string
-	executionID string
+	trace        string
+	spanID       string
+	traceSampled bool
+	executionID  string
 }
 
 type contextKey string
 
+// addLoggingIDsToRequest adds the logging IDs of r, from its headers or
+// generated, and a description of r for log entries to the context of r. r is
+// returned unchanged if its context already carries logging IDs.
 func addLoggingIDsToRequest(r *http.Request) *http.Request {
-	executionID := r.Header.Get("Function-Execution-Id")
+	if loggingIDsFromContext(r.Context()) != nil {
+		return r
+	}
+	executionID := r.Header.Get(executionIDHeader)
 	if executionID == "" {
 		timestamp := time.Now().UnixNano()
 		random := rand.Int63()
 		executionID = fmt.Sprintf("%06x%06x", timestamp, random)
 	}
-	traceID, spanID, _ := deconstructXCloudTraceContext(r.Header.Get("X-Cloud-Trace-Context"))
+	// W3C trace context takes precedence over X-Cloud-Trace-Context when both
+	// are present and valid.
+	traceID, spanID, traceSampled := deconstructTraceparent(r.Header.Get("traceparent"))
+	if traceID == "" {
+		traceID, spanID, traceSampled = deconstructXCloudTraceContext(r.Header.Get("X-Cloud-Trace-Context"))
+	}
 
 	if executionID == "" && traceID == "" && spanID == "" {
 		return r
 	}
 
-	r = r.WithContext(contextWithLoggingIDs(r.Context(), &loggingIDs{
-		trace:       traceID,
-		spanID:      spanID,
-		executionID: executionID,
-	}))
+	ctx := contextWithLoggingIDs(r.Context(), &loggingIDs{
+		trace:        traceID,
+		spanID:       spanID,
+		traceSampled: traceSampled,
+		executionID:  executionID,
+	})
+	ctx = context.WithValue(ctx, httpRequestContextKey, newHTTPRequestLog(r))
+
+	return r.WithContext(ctx)
+}
+
+// httpRequestLog declares a subset of the fields of the Cloud Logging
+// HttpRequest type that is attached to structured log entries. See
+// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#HttpRequest.
+type httpRequestLog struct {
+	RequestMethod string `json:"requestMethod,omitempty"`
+	RequestURL    string `json:"requestUrl,omitempty"`
+	UserAgent     string `json:"userAgent,omitempty"`
+	RemoteIP      string `json:"remoteIp,omitempty"`
+	Referer       string `json:"referer,omitempty"`
+	Protocol      string `json:"protocol,omitempty"`
+	// Status, ResponseSize and Latency are only known once the invocation
+	// completes and are set in access log entries.
+	Status       int    `json:"status,omitempty"`
+	ResponseSize string `json:"responseSize,omitempty"`
+	Latency      string `json:"latency,omitempty"`
+}
+
+func newHTTPRequestLog(r *http.Request) *httpRequestLog {
+	remoteIP := r.Header.Get("X-Forwarded-For")
+	if i := strings.IndexByte(remoteIP, ','); i >= 0 {
+		remoteIP = remoteIP[:i]
+	}
+	if remoteIP == "" {
+		remoteIP = r.RemoteAddr
+	}
+	return &httpRequestLog{
+		RequestMethod: r.Method,
+		RequestURL:    r.URL.String(),
+		UserAgent:     r.UserAgent(),
+		RemoteIP:      strings.TrimSpace(remoteIP),
+		Referer:       r.Referer(),
+		Protocol:      r.Proto,
+	}
+}
 
-	return r
+func httpRequestFromContext(ctx context.Context) *httpRequestLog {
+	val := ctx.Value(httpRequestContextKey)
+	if val == nil {
+		return nil
+	}
+	return val.(*httpRequestLog)
 }
 
 func contextWithLoggingIDs(ctx context.Context, loggingIDs *loggingIDs) context.Context {
@@ -91,6 +160,17 @@ func SpanIDFromContext(ctx context.Context) string {
 	return ids.spanID
 }
 
+// TraceSampledFromContext reports whether the trace of the request was
+// sampled, as indicated by the flags of the traceparent or
+// X-Cloud-Trace-Context header.
+func TraceSampledFromContext(ctx context.Context) bool {
+	ids := loggingIDsFromContext(ctx)
+	if ids == nil {
+		return false
+	}
+	return ids.traceSampled
+}
+
 func deconstructXCloudTraceContext(s string) (traceID, spanID string, traceSampled bool) {
 	// As per the format described at https://cloud.google.com/trace/docs/setup#force-trace
 	//    "X-Cloud-Trace-Context: TRACE_ID/SPAN_ID;o=TRACE_TRUE"
@@ -106,28 +186,87 @@ func deconstructXCloudTraceContext(s string) (traceID, spanID string, traceSampl
 	return
 }
 
+// deconstructTraceparent parses a W3C traceparent value. Trace and span IDs
+// that are all zeros are invalid and are returned as empty strings.
+func deconstructTraceparent(s string) (traceID, spanID string, traceSampled bool) {
+	// As per the format described at https://www.w3.org/TR/trace-context/#traceparent-header
+	//    "traceparent: VERSION-TRACE_ID-PARENT_ID-TRACE_FLAGS"
+	// for example:
+	//    "traceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	matches := validTraceparent.FindStringSubmatch(strings.TrimSpace(s))
+	if matches == nil || matches[1] == "ff" {
+		return
+	}
+	if strings.Trim(matches[2], "0") == "" || strings.Trim(matches[3], "0") == "" {
+		return
+	}
+	flags, err := strconv.ParseUint(matches[4], 16, 8)
+	if err != nil {
+		return
+	}
+	return matches[2], matches[3], flags&0x01 == 1
+}
+
+// contextWithTraceparent replaces the trace and span IDs of the logging IDs in
+// ctx with the ones from the W3C traceparent value tp, if it is valid.
+func contextWithTraceparent(ctx context.Context, tp string) context.Context {
+	traceID, spanID, traceSampled := deconstructTraceparent(tp)
+	if traceID == "" {
+		return ctx
+	}
+	var ids loggingIDs
+	if existing := loggingIDsFromContext(ctx); existing != nil {
+		ids = *existing
+	}
+	ids.trace = traceID
+	ids.spanID = spanID
+	ids.traceSampled = traceSampled
+	return contextWithLoggingIDs(ctx, &ids)
+}
+
+// LogSeverity is the severity of a log entry. See
+// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogSeverity.
+type LogSeverity string
+
+const (
+	SeverityDefault   LogSeverity = "DEFAULT"
+	SeverityDebug     LogSeverity = "DEBUG"
+	SeverityInfo      LogSeverity = "INFO"
+	SeverityNotice    LogSeverity = "NOTICE"
+	SeverityWarning   LogSeverity = "WARNING"
+	SeverityError     LogSeverity = "ERROR"
+	SeverityCritical  LogSeverity = "CRITICAL"
+	SeverityAlert     LogSeverity = "ALERT"
+	SeverityEmergency LogSeverity = "EMERGENCY"
+)
+
 // structuredLogEvent declares a subset of the fields supported by cloudlogging structured log events.
 // See https://cloud.google.com/logging/docs/structured-logging.
 type structuredLogEvent struct {
-	Message string            `json:"message"`
-	Trace   string            `json:"logging.googleapis.com/trace,omitempty"`
-	SpanID  string            `json:"logging.googleapis.com/spanId,omitempty"`
-	Labels  map[string]string `json:"logging.googleapis.com/labels,omitempty"`
+	Severity     LogSeverity       `json:"severity,omitempty"`
+	Message      string            `json:"message"`
+	Trace        string            `json:"logging.googleapis.com/trace,omitempty"`
+	SpanID       string            `json:"logging.googleapis.com/spanId,omitempty"`
+	TraceSampled bool              `json:"logging.googleapis.com/trace_sampled,omitempty"`
+	Labels       map[string]string `json:"logging.googleapis.com/labels,omitempty"`
 }
 
 // structuredLogWriter writes structured logs
 type structuredLogWriter struct {
 	mu         sync.Mutex
 	w          io.Writer
+	severity   LogSeverity
 	loggingIDs loggingIDs
 	buf        []byte
 }
 
 func (w *structuredLogWriter) writeStructuredLog(loggingIDs loggingIDs, message string) (int, error) {
 	event := structuredLogEvent{
-		Message: message,
-		Trace:   loggingIDs.trace,
-		SpanID:  loggingIDs.spanID,
+		Severity:     w.severity,
+		Message:      message,
+		Trace:        loggingIDs.trace,
+		SpanID:       loggingIDs.spanID,
+		TraceSampled: loggingIDs.traceSampled,
 	}
 	if loggingIDs.executionID != "" {
 		event.Labels = map[string]string{
@@ -194,13 +333,92 @@ func (w *structuredLogWriter) Close() error {
 //	  l.Println("hello world!")
 //	}
 func LogWriter(ctx context.Context) io.WriteCloser {
+	return LogWriterWithSeverity(ctx, "")
+}
+
+// LogWriterWithSeverity is like LogWriter, but every log event generated is
+// given the severity sev, for example:
+//
+//	func helloWorld(w http.ResponseWriter, r *http.Request) {
+//	  l := log.New(funcframework.LogWriterWithSeverity(r.Context(), funcframework.SeverityWarning), "", 0)
+//	  l.Println("something looks wrong")
+//	}
+//
+// Unlike LogWriter, log events are structured even if ctx carries no logging
+// IDs so that the severity is not lost.
+func LogWriterWithSeverity(ctx context.Context, sev LogSeverity) io.WriteCloser {
+	out := logOutput(ctx)
 	loggingIDs := loggingIDsFromContext(ctx)
 	if loggingIDs == nil {
-		return os.Stderr
+		if sev == "" {
+			if wc, ok := out.(io.WriteCloser); ok {
+				return wc
+			}
+			return nopWriteCloser{out}
+		}
+		return &structuredLogWriter{
+			w:        out,
+			severity: sev,
+		}
 	}
 
 	return &structuredLogWriter{
-		w:          os.Stderr,
+		w:          out,
+		severity:   sev,
 		loggingIDs: *loggingIDs,
 	}
 }
+
+// logFrameworkError writes msg, an error reported by the framework rather than
+// logged by the function, to stderr. When running on GCF, msg is written as a
+// single structured log event with ERROR severity and the logging IDs of ctx,
+// so that multi-line messages such as stack traces are kept in one entry and
+// correlated with the request.
+func logFrameworkError(ctx context.Context, msg string) {
+	// Ensure logs end with a newline otherwise they are grouped incorrectly in SD.
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+	out := logOutput(ctx)
+	if !currentConfig().onGCF() {
+		fmt.Fprint(out, msg)
+		return
+	}
+
+	w := &structuredLogWriter{
+		w:        out,
+		severity: SeverityError,
+	}
+	if ctx != nil {
+		if loggingIDs := loggingIDsFromContext(ctx); loggingIDs != nil {
+			w.loggingIDs = *loggingIDs
+		}
+	}
+	if _, err := w.writeStructuredLog(w.loggingIDs, strings.TrimSuffix(msg, "\n")); err != nil {
+		fmt.Fprint(out, msg)
+	}
+}
+
+// contextWithLogOutput returns a copy of ctx in which the log entries of the
+// invocation, written by the function with LogWriter or Logger or by the
+// framework, go to w instead of stderr.
+func contextWithLogOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, logOutputContextKey, w)
+}
+
+// logOutput returns the writer the log entries of the invocation with context
+// ctx go to, which is stderr unless set by contextWithLogOutput.
+func logOutput(ctx context.Context) io.Writer {
+	if ctx != nil {
+		if w, ok := ctx.Value(logOutputContextKey).(io.Writer); ok {
+			return w
+		}
+	}
+	return os.Stderr
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
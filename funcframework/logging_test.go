@@ -2,9 +2,12 @@ package funcframework
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"net/http/httptest"
+	"os"
 	"testing"
 )
 
@@ -15,6 +18,7 @@ func TestLoggingIDExtraction(t *testing.T) {
 		wantTraceID     string
 		wantSpanID      string
 		wantExecutionID string
+		wantSampled     bool
 		randomExecutionIdGenerated	bool
 	}{
 		{
@@ -63,6 +67,48 @@ func TestLoggingIDExtraction(t *testing.T) {
 			wantSpanID:      "b",
 			wantExecutionID: "c",
 		},
+		{
+			name: "sampled X-Cloud-Trace-Context",
+			headers: map[string]string{
+				"X-Cloud-Trace-Context": "0123456789abcdef/aaaaaa;o=1",
+			},
+			wantTraceID: "0123456789abcdef",
+			wantSpanID:  "aaaaaa",
+			wantSampled: true,
+			randomExecutionIdGenerated: true,
+		},
+		{
+			name: "traceparent",
+			headers: map[string]string{
+				"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				"tracestate":  "congo=t61rcWkgMzE",
+			},
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantSpanID:  "00f067aa0ba902b7",
+			wantSampled: true,
+			randomExecutionIdGenerated: true,
+		},
+		{
+			name: "traceparent preferred over X-Cloud-Trace-Context",
+			headers: map[string]string{
+				"traceparent":           "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+				"X-Cloud-Trace-Context": "0123456789abcdef/aaaaaa;o=1",
+			},
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantSpanID:  "00f067aa0ba902b7",
+			randomExecutionIdGenerated: true,
+		},
+		{
+			name: "malformed traceparent falls back to X-Cloud-Trace-Context",
+			headers: map[string]string{
+				"traceparent":           "$*#$(v434)",
+				"X-Cloud-Trace-Context": "0123456789abcdef/aaaaaa;o=1",
+			},
+			wantTraceID: "0123456789abcdef",
+			wantSpanID:  "aaaaaa",
+			wantSampled: true,
+			randomExecutionIdGenerated: true,
+		},
 	}
 
 	for _, tc := range tcs {
@@ -82,6 +128,10 @@ func TestLoggingIDExtraction(t *testing.T) {
 				t.Errorf("expected span id %q but got %q", tc.wantSpanID, spid)
 			}
 
+			if sampled := TraceSampledFromContext(ctx); sampled != tc.wantSampled {
+				t.Errorf("expected trace sampled %v but got %v", tc.wantSampled, sampled)
+			}
+
 			eid := ExecutionIDFromContext(ctx); 
 			if tc.wantExecutionID != "" && eid != tc.wantExecutionID {
 				t.Errorf("expected execution id %q but got %q", tc.wantExecutionID, eid)
@@ -93,6 +143,68 @@ func TestLoggingIDExtraction(t *testing.T) {
 	}
 }
 
+func TestDeconstructTraceparent(t *testing.T) {
+	tcs := []struct {
+		name        string
+		traceparent string
+		wantTraceID string
+		wantSpanID  string
+		wantSampled bool
+	}{
+		{
+			name:        "sampled",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantSpanID:  "00f067aa0ba902b7",
+			wantSampled: true,
+		},
+		{
+			name:        "not sampled",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantSpanID:  "00f067aa0ba902b7",
+		},
+		{
+			name:        "future version with extra fields",
+			traceparent: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			wantTraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantSpanID:  "00f067aa0ba902b7",
+			wantSampled: true,
+		},
+		{
+			name:        "invalid version",
+			traceparent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		},
+		{
+			name:        "all zero trace ID",
+			traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		},
+		{
+			name:        "all zero span ID",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		},
+		{
+			name:        "malformed",
+			traceparent: "$*#$(v434)",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			traceID, spanID, sampled := deconstructTraceparent(tc.traceparent)
+			if traceID != tc.wantTraceID {
+				t.Errorf("expected trace id %q but got %q", tc.wantTraceID, traceID)
+			}
+			if spanID != tc.wantSpanID {
+				t.Errorf("expected span id %q but got %q", tc.wantSpanID, spanID)
+			}
+			if sampled != tc.wantSampled {
+				t.Errorf("expected sampled %v but got %v", tc.wantSampled, sampled)
+			}
+		})
+	}
+}
+
 func TestStructuredLogWriter(t *testing.T) {
 	output := bytes.NewBuffer(nil)
 
@@ -116,6 +228,118 @@ func TestStructuredLogWriter(t *testing.T) {
 	}
 }
 
+func TestStructuredLogWriterTraceSampled(t *testing.T) {
+	output := bytes.NewBuffer(nil)
+
+	w := &structuredLogWriter{
+		w: output,
+		loggingIDs: loggingIDs{
+			spanID:       "a",
+			trace:        "b",
+			traceSampled: true,
+			executionID:  "c",
+		},
+	}
+
+	fmt.Fprintf(w, "hello world!\n")
+
+	wantOutput := `{"message":"hello world!","logging.googleapis.com/trace":"b","logging.googleapis.com/spanId":"a","logging.googleapis.com/trace_sampled":true,"logging.googleapis.com/labels":{"execution_id":"c"}}
+`
+	if output.String() != wantOutput {
+		t.Errorf("expected output %q got %q", wantOutput, output.String())
+	}
+}
+
+func TestStructuredLogWriterSeverity(t *testing.T) {
+	output := bytes.NewBuffer(nil)
+
+	w := &structuredLogWriter{
+		w:        output,
+		severity: SeverityWarning,
+		loggingIDs: loggingIDs{
+			executionID: "c",
+		},
+	}
+
+	fmt.Fprintf(w, "hello world!\n")
+
+	wantOutput := `{"severity":"WARNING","message":"hello world!","logging.googleapis.com/labels":{"execution_id":"c"}}
+`
+	if output.String() != wantOutput {
+		t.Errorf("expected output %q got %q", wantOutput, output.String())
+	}
+}
+
+func TestLogWriterWithSeverity(t *testing.T) {
+	if w := LogWriterWithSeverity(context.Background(), ""); w != os.Stderr {
+		t.Errorf("LogWriterWithSeverity() without logging IDs or severity = %v, want os.Stderr", w)
+	}
+
+	w, ok := LogWriterWithSeverity(context.Background(), SeverityError).(*structuredLogWriter)
+	if !ok {
+		t.Fatalf("LogWriterWithSeverity() with severity is not structured")
+	}
+	if w.severity != SeverityError {
+		t.Errorf("LogWriterWithSeverity().severity = %q, want %q", w.severity, SeverityError)
+	}
+
+	ctx := contextWithLoggingIDs(context.Background(), &loggingIDs{executionID: "c"})
+	w, ok = LogWriterWithSeverity(ctx, SeverityInfo).(*structuredLogWriter)
+	if !ok {
+		t.Fatalf("LogWriterWithSeverity() with logging IDs is not structured")
+	}
+	if w.severity != SeverityInfo || w.loggingIDs.executionID != "c" {
+		t.Errorf("LogWriterWithSeverity() = %+v, want severity %q and execution ID %q", w, SeverityInfo, "c")
+	}
+}
+
+func TestLogFrameworkError(t *testing.T) {
+	ctx := contextWithLoggingIDs(context.Background(), &loggingIDs{
+		trace:       "b",
+		spanID:      "a",
+		executionID: "c",
+	})
+
+	tcs := []struct {
+		name       string
+		kService   string
+		wantOutput string
+	}{
+		{
+			name:       "local",
+			wantOutput: "something failed\nwith details\n",
+		},
+		{
+			name:     "GCF",
+			kService: "test-service",
+			wantOutput: `{"severity":"ERROR","message":"something failed\nwith details","logging.googleapis.com/trace":"b","logging.googleapis.com/spanId":"a","logging.googleapis.com/labels":{"execution_id":"c"}}
+`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("K_SERVICE", tc.kService)
+
+			origStderr := os.Stderr
+			r, w, _ := os.Pipe()
+			os.Stderr = w
+			defer func() { os.Stderr = origStderr }()
+
+			logFrameworkError(ctx, "something failed\nwith details")
+
+			w.Close()
+			output, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("failed to read stderr: %v", err)
+			}
+			if string(output) != tc.wantOutput {
+				t.Errorf("expected output %q got %q", tc.wantOutput, string(output))
+			}
+		})
+	}
+}
+
 func TestLogPackageCompat(t *testing.T) {
 	output := bytes.NewBuffer(nil)
 	w := &structuredLogWriter{
@@ -0,0 +1,205 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultIdleTimeout       = 2 * time.Minute
+	defaultMaxHeaderBytes    = http.DefaultMaxHeaderBytes
+)
+
+// ServerOption configures the HTTP server started by Start, StartHostPort and
+// StartWithConfig.
+type ServerOption func(*serverConfig)
+
+type serverConfig struct {
+	readHeaderTimeout time.Duration
+	idleTimeout       time.Duration
+	maxHeaderBytes    int
+	h2c               bool
+
+	tlsConfig    *tls.Config
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	listener net.Listener
+
+	accessLog bool
+}
+
+// usesTLS reports whether the server serves HTTPS.
+func (c serverConfig) usesTLS() bool {
+	return c.tlsConfig != nil || c.certFile != "" || c.clientCAFile != ""
+}
+
+func newServerConfig(opts []ServerOption) serverConfig {
+	cfg := serverConfig{
+		readHeaderTimeout: defaultReadHeaderTimeout,
+		idleTimeout:       defaultIdleTimeout,
+		maxHeaderBytes:    defaultMaxHeaderBytes,
+	}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	return cfg
+}
+
+// WithReadHeaderTimeout sets how long the server waits for the headers of a
+// request, which protects it against clients sending them very slowly.
+// Defaults to 10 seconds.
+func WithReadHeaderTimeout(d time.Duration) ServerOption {
+	return func(c *serverConfig) {
+		c.readHeaderTimeout = d
+	}
+}
+
+// WithIdleTimeout sets how long the server keeps an idle keep-alive connection
+// open. Defaults to 2 minutes.
+func WithIdleTimeout(d time.Duration) ServerOption {
+	return func(c *serverConfig) {
+		c.idleTimeout = d
+	}
+}
+
+// WithMaxHeaderBytes sets the maximum size of the headers of a request.
+// Defaults to 1 MB.
+func WithMaxHeaderBytes(n int) ServerOption {
+	return func(c *serverConfig) {
+		c.maxHeaderBytes = n
+	}
+}
+
+// WithH2C makes the server accept HTTP/2 over cleartext connections (h2c), in
+// addition to HTTP/1.1. This is required to use end-to-end HTTP/2 on Cloud
+// Run, which forwards HTTP/2 requests to the container without TLS.
+func WithH2C() ServerOption {
+	return func(c *serverConfig) {
+		c.h2c = true
+	}
+}
+
+// WithTLS makes the server serve HTTPS with the certificate and private key
+// in the given PEM files.
+func WithTLS(certFile, keyFile string) ServerOption {
+	return func(c *serverConfig) {
+		c.certFile = certFile
+		c.keyFile = keyFile
+	}
+}
+
+// WithClientCAs makes the server require clients to present a certificate
+// signed by one of the certificate authorities in the given PEM file (mutual
+// TLS). It must be used along with WithTLS or WithTLSConfig.
+func WithClientCAs(caFile string) ServerOption {
+	return func(c *serverConfig) {
+		c.clientCAFile = caFile
+	}
+}
+
+// WithTLSConfig makes the server serve HTTPS with the given TLS configuration.
+// The certificates and client certificate authorities set with WithTLS and
+// WithClientCAs are added to a copy of cfg.
+func WithTLSConfig(cfg *tls.Config) ServerOption {
+	return func(c *serverConfig) {
+		c.tlsConfig = cfg
+	}
+}
+
+// WithListener makes the server accept connections on l instead of listening
+// on the configured host and port, for example a listener inherited from a
+// parent process. The port passed to Start or StartHostPort, or configured
+// for StartWithConfig, is then ignored.
+func WithListener(l net.Listener) ServerOption {
+	return func(c *serverConfig) {
+		c.listener = l
+	}
+}
+
+// newHTTPServer returns the server serving handler, configured by cfg.
+func newHTTPServer(handler http.Handler, cfg serverConfig) (*http.Server, error) {
+	if cfg.readHeaderTimeout <= 0 || cfg.idleTimeout <= 0 || cfg.maxHeaderBytes <= 0 {
+		return nil, fmt.Errorf("invalid server configuration: timeouts and max header bytes must be positive")
+	}
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.readHeaderTimeout,
+		IdleTimeout:       cfg.idleTimeout,
+		MaxHeaderBytes:    cfg.maxHeaderBytes,
+	}
+	if cfg.usesTLS() {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		srv.TLSConfig = tlsConfig
+	}
+	if cfg.h2c || cfg.usesTLS() {
+		h2s := &http2.Server{IdleTimeout: cfg.idleTimeout}
+		// ConfigureServer enables HTTP/2 over TLS and lets srv.Shutdown
+		// close the HTTP/2 connections gracefully too.
+		if err := http2.ConfigureServer(srv, h2s); err != nil {
+			return nil, fmt.Errorf("failed to configure HTTP/2: %v", err)
+		}
+		if cfg.h2c {
+			srv.Handler = h2c.NewHandler(handler, h2s)
+		}
+	}
+	return srv, nil
+}
+
+// newTLSConfig returns the TLS configuration of a server configured by cfg.
+func newTLSConfig(cfg serverConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.tlsConfig != nil {
+		tlsConfig = cfg.tlsConfig.Clone()
+	}
+	if cfg.certFile != "" || cfg.keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.certFile, cfg.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %v", err)
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+	if cfg.clientCAFile != "" {
+		pem, err := os.ReadFile(cfg.clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CAs: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CAs file %q", cfg.clientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if len(tlsConfig.Certificates) == 0 && tlsConfig.GetCertificate == nil && tlsConfig.GetConfigForClient == nil {
+		return nil, fmt.Errorf("invalid TLS configuration: no server certificate")
+	}
+	return tlsConfig, nil
+}
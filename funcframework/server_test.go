@@ -0,0 +1,340 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"golang.org/x/net/http2"
+)
+
+func TestNewHTTPServer(t *testing.T) {
+	tcs := []struct {
+		name    string
+		opts    []ServerOption
+		want    serverConfig
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: serverConfig{
+				readHeaderTimeout: 10 * time.Second,
+				idleTimeout:       2 * time.Minute,
+				maxHeaderBytes:    http.DefaultMaxHeaderBytes,
+			},
+		},
+		{
+			name: "options",
+			opts: []ServerOption{
+				WithReadHeaderTimeout(time.Second),
+				WithIdleTimeout(time.Minute),
+				WithMaxHeaderBytes(4096),
+			},
+			want: serverConfig{
+				readHeaderTimeout: time.Second,
+				idleTimeout:       time.Minute,
+				maxHeaderBytes:    4096,
+			},
+		},
+		{
+			name:    "zero timeout",
+			opts:    []ServerOption{WithReadHeaderTimeout(0)},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, err := newHTTPServer(http.NotFoundHandler(), newServerConfig(tc.opts))
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("newHTTPServer() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newHTTPServer(): %v", err)
+			}
+			if srv.ReadHeaderTimeout != tc.want.readHeaderTimeout {
+				t.Errorf("ReadHeaderTimeout = %v, want %v", srv.ReadHeaderTimeout, tc.want.readHeaderTimeout)
+			}
+			if srv.IdleTimeout != tc.want.idleTimeout {
+				t.Errorf("IdleTimeout = %v, want %v", srv.IdleTimeout, tc.want.idleTimeout)
+			}
+			if srv.MaxHeaderBytes != tc.want.maxHeaderBytes {
+				t.Errorf("MaxHeaderBytes = %v, want %v", srv.MaxHeaderBytes, tc.want.maxHeaderBytes)
+			}
+		})
+	}
+}
+
+func TestH2C(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		var opts []ServerOption
+		if enabled {
+			opts = append(opts, WithH2C())
+		}
+		srv, err := newHTTPServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, r.Proto)
+		}), newServerConfig(opts))
+		if err != nil {
+			t.Fatalf("newHTTPServer(): %v", err)
+		}
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("net.Listen(): %v", err)
+		}
+		go srv.Serve(l)
+
+		// An HTTP/2 client using prior knowledge, as Cloud Run does.
+		client := &http.Client{Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		}}
+		resp, err := client.Get("http://" + l.Addr().String())
+		if enabled {
+			if err != nil {
+				t.Fatalf("h2c request: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != "HTTP/2.0" {
+				t.Errorf("h2c request protocol = %q, want %q", body, "HTTP/2.0")
+			}
+		} else if err == nil {
+			resp.Body.Close()
+			t.Errorf("h2c request succeeded without WithH2C")
+		}
+		srv.Close()
+	}
+}
+
+// testCert is a certificate and its private key written to PEM files.
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	tls      tls.Certificate
+	certFile string
+	keyFile  string
+}
+
+// newTestCert creates a certificate for localhost signed by parent, or a
+// self-signed CA certificate if parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey(): %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+		tmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate(): %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("x509.ParseCertificate(): %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("x509.MarshalECPrivateKey(): %v", err)
+	}
+
+	dir := t.TempDir()
+	c := &testCert{
+		cert:     cert,
+		key:      key,
+		tls:      tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+		certFile: filepath.Join(dir, name+".pem"),
+		keyFile:  filepath.Join(dir, name+"-key.pem"),
+	}
+	if err := os.WriteFile(c.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("writing certificate: %v", err)
+	}
+	if err := os.WriteFile(c.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("writing private key: %v", err)
+	}
+	return c
+}
+
+func TestTLS(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	serverCert := newTestCert(t, "server", ca)
+	clientCert := newTestCert(t, "client", ca)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	tcs := []struct {
+		name       string
+		opts       []ServerOption
+		clientCert *testCert
+		wantErr    bool
+	}{
+		{
+			name: "TLS",
+			opts: []ServerOption{WithTLS(serverCert.certFile, serverCert.keyFile)},
+		},
+		{
+			name: "TLS config",
+			opts: []ServerOption{WithTLSConfig(&tls.Config{Certificates: []tls.Certificate{serverCert.tls}})},
+		},
+		{
+			name:       "mTLS",
+			opts:       []ServerOption{WithTLS(serverCert.certFile, serverCert.keyFile), WithClientCAs(ca.certFile)},
+			clientCert: clientCert,
+		},
+		{
+			name:    "mTLS without client certificate",
+			opts:    []ServerOption{WithTLS(serverCert.certFile, serverCert.keyFile), WithClientCAs(ca.certFile)},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newServerConfig(tc.opts)
+			srv, err := newHTTPServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, r.Proto)
+			}), cfg)
+			if err != nil {
+				t.Fatalf("newHTTPServer(): %v", err)
+			}
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("net.Listen(): %v", err)
+			}
+			go srv.Serve(tls.NewListener(l, srv.TLSConfig))
+			defer srv.Close()
+
+			clientTLS := &tls.Config{RootCAs: roots}
+			if tc.clientCert != nil {
+				clientTLS.Certificates = []tls.Certificate{tc.clientCert.tls}
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS, ForceAttemptHTTP2: true}}
+			resp, err := client.Get("https://" + l.Addr().String())
+			if tc.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Errorf("request succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != "HTTP/2.0" {
+				t.Errorf("request protocol = %q, want %q", body, "HTTP/2.0")
+			}
+		})
+	}
+}
+
+func TestNewHTTPServerInvalidTLS(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	tcs := []struct {
+		name string
+		opts []ServerOption
+	}{
+		{
+			name: "missing certificate",
+			opts: []ServerOption{WithTLS(filepath.Join(t.TempDir(), "missing.pem"), ca.keyFile)},
+		},
+		{
+			name: "client CAs without certificate",
+			opts: []ServerOption{WithClientCAs(ca.certFile)},
+		},
+		{
+			name: "invalid client CAs",
+			opts: []ServerOption{WithTLS(ca.certFile, ca.keyFile), WithClientCAs(ca.keyFile)},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := newHTTPServer(http.NotFoundHandler(), newServerConfig(tc.opts)); err == nil {
+				t.Errorf("newHTTPServer() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestWithListener(t *testing.T) {
+	defer cleanup()
+
+	functions.HTTP("fn", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "Hello World!")
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen(): %v", err)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		// The listener is used instead of the configured port.
+		errc <- StartWithConfig(&Config{Port: "0"}, WithListener(l))
+	}()
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/fn", l.Addr()))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "Hello World!" {
+		t.Errorf("response body = %q, want %q", body, "Hello World!")
+	}
+
+	l.Close()
+	if err := <-errc; err == nil {
+		t.Errorf("StartWithConfig() = nil after closing the listener, want error")
+	}
+}
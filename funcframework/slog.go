@@ -0,0 +1,265 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Cloud Logging special fields, see
+// https://cloud.google.com/logging/docs/structured-logging#special-payload-fields.
+const (
+	severityKey       = "severity"
+	messageKey        = "message"
+	timeKey           = "time"
+	sourceLocationKey = "logging.googleapis.com/sourceLocation"
+	traceKey          = "logging.googleapis.com/trace"
+	spanIDKey         = "logging.googleapis.com/spanId"
+	traceSampledKey   = "logging.googleapis.com/trace_sampled"
+	labelsKey         = "logging.googleapis.com/labels"
+	httpRequestKey    = "httpRequest"
+)
+
+// LogHandler is a slog.Handler that writes each record as a single line of
+// JSON in the Cloud Logging structured logging format. The record level is
+// mapped to "severity", attributes become fields of the entry's jsonPayload,
+// and the trace, span and execution IDs and the HTTP request of the invocation
+// are taken from the context passed to the logger.
+type LogHandler struct {
+	opts slog.HandlerOptions
+	mu   *sync.Mutex
+	w    io.Writer
+	// ctx is used for records logged without a context, e.g. with Info
+	// rather than InfoContext.
+	ctx    context.Context
+	groups []string
+	attrs  []groupedAttrs
+}
+
+// groupedAttrs are attributes added by WithAttrs, along with the groups that
+// were open when they were added.
+type groupedAttrs struct {
+	groups []string
+	attrs  []slog.Attr
+}
+
+// NewLogHandler returns a LogHandler that writes to w. If opts is nil, the
+// default options are used.
+func NewLogHandler(w io.Writer, opts *slog.HandlerOptions) *LogHandler {
+	h := &LogHandler{
+		mu:  &sync.Mutex{},
+		w:   w,
+		ctx: context.Background(),
+	}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+// Logger returns a structured logger for the invocation with context ctx.
+// Entries are written to stderr in the Cloud Logging structured logging format
+// and include the source location of the log call and the trace, span and
+// execution IDs of the invocation, for example:
+//
+//	func helloWorld(w http.ResponseWriter, r *http.Request) {
+//	  funcframework.Logger(r.Context()).Info("hello world!", "user", user)
+//	}
+func Logger(ctx context.Context) *slog.Logger {
+	h := NewLogHandler(logOutput(ctx), &slog.HandlerOptions{AddSource: true})
+	h.ctx = ctx
+	return slog.New(h)
+}
+
+// Enabled implements slog.Handler.
+func (h *LogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+	return level >= minLevel
+}
+
+// WithAttrs implements slog.Handler.
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = append(append([]groupedAttrs{}, h.attrs...), groupedAttrs{groups: h.groups, attrs: attrs})
+	return &h2
+}
+
+// WithGroup implements slog.Handler.
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(append([]string{}, h.groups...), name)
+	return &h2
+}
+
+// Handle implements slog.Handler.
+func (h *LogHandler) Handle(ctx context.Context, r slog.Record) error {
+	entry := map[string]interface{}{}
+	for _, ga := range h.attrs {
+		h.addAttrs(groupMap(entry, ga.groups), ga.groups, ga.attrs)
+	}
+	var attrs []slog.Attr
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	h.addAttrs(groupMap(entry, h.groups), h.groups, attrs)
+
+	entry[severityKey] = severity(r.Level)
+	entry[messageKey] = r.Message
+	if !r.Time.IsZero() {
+		entry[timeKey] = r.Time.Format(time.RFC3339Nano)
+	}
+	if h.opts.AddSource && r.PC != 0 {
+		frames := runtime.CallersFrames([]uintptr{r.PC})
+		f, _ := frames.Next()
+		entry[sourceLocationKey] = map[string]string{
+			"file":     f.File,
+			"line":     strconv.Itoa(f.Line),
+			"function": f.Function,
+		}
+	}
+
+	if ctx == nil || loggingIDsFromContext(ctx) == nil {
+		ctx = h.ctx
+	}
+	addLoggingContext(entry, ctx)
+
+	marshalled, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	marshalled = append(marshalled, '\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err = h.w.Write(marshalled)
+	return err
+}
+
+// addLoggingContext adds the logging IDs and HTTP request of the invocation
+// with context ctx to entry.
+func addLoggingContext(entry map[string]interface{}, ctx context.Context) {
+	if ids := loggingIDsFromContext(ctx); ids != nil {
+		if ids.trace != "" {
+			entry[traceKey] = ids.trace
+		}
+		if ids.spanID != "" {
+			entry[spanIDKey] = ids.spanID
+		}
+		if ids.traceSampled {
+			entry[traceSampledKey] = true
+		}
+		if ids.executionID != "" {
+			entry[labelsKey] = map[string]string{
+				"execution_id": ids.executionID,
+			}
+		}
+	}
+	if req := httpRequestFromContext(ctx); req != nil {
+		entry[httpRequestKey] = req
+	}
+}
+
+func (h *LogHandler) addAttrs(m map[string]interface{}, groups []string, attrs []slog.Attr) {
+	for _, a := range attrs {
+		if h.opts.ReplaceAttr != nil && a.Value.Kind() != slog.KindGroup {
+			a = h.opts.ReplaceAttr(groups, a)
+		}
+		a.Value = a.Value.Resolve()
+		if a.Equal(slog.Attr{}) {
+			continue
+		}
+		if a.Value.Kind() == slog.KindGroup {
+			group := a.Value.Group()
+			if len(group) == 0 {
+				continue
+			}
+			if a.Key == "" {
+				// Attributes of groups with an empty key are inlined.
+				h.addAttrs(m, groups, group)
+				continue
+			}
+			h.addAttrs(groupMap(m, []string{a.Key}), append(append([]string{}, groups...), a.Key), group)
+			continue
+		}
+		m[a.Key] = attrValue(a.Value)
+	}
+}
+
+// groupMap returns the nested map in m for the given groups, creating it if
+// needed.
+func groupMap(m map[string]interface{}, groups []string) map[string]interface{} {
+	for _, g := range groups {
+		sub, ok := m[g].(map[string]interface{})
+		if !ok {
+			sub = map[string]interface{}{}
+			m[g] = sub
+		}
+		m = sub
+	}
+	return m
+}
+
+func attrValue(v slog.Value) interface{} {
+	switch v.Kind() {
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindAny:
+		switch a := v.Any().(type) {
+		case json.Marshaler:
+			return a
+		case error:
+			return a.Error()
+		case fmt.Stringer:
+			return a.String()
+		}
+	}
+	return v.Any()
+}
+
+// severity maps a slog.Level to a Cloud Logging LogSeverity.
+func severity(l slog.Level) LogSeverity {
+	switch {
+	case l < slog.LevelInfo:
+		return SeverityDebug
+	case l < slog.LevelWarn:
+		return SeverityInfo
+	case l < slog.LevelError:
+		return SeverityWarning
+	case l < slog.LevelError+4:
+		return SeverityError
+	default:
+		return SeverityCritical
+	}
+}
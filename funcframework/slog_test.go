@@ -0,0 +1,172 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestLogHandler(t *testing.T) {
+	r := httptest.NewRequest("POST", "/fn?q=1", bytes.NewReader(nil))
+	r.Header.Set("X-Cloud-Trace-Context", "0123456789abcdef/aaaaaa;o=1")
+	r.Header.Set("Function-Execution-Id", "exec")
+	r.Header.Set("User-Agent", "test-agent")
+	r = addLoggingIDsToRequest(r)
+
+	loggingFields := map[string]interface{}{
+		"logging.googleapis.com/trace":         "0123456789abcdef",
+		"logging.googleapis.com/spanId":        "aaaaaa",
+		"logging.googleapis.com/trace_sampled": true,
+		"logging.googleapis.com/labels":        map[string]interface{}{"execution_id": "exec"},
+		"httpRequest": map[string]interface{}{
+			"requestMethod": "POST",
+			"requestUrl":    "/fn?q=1",
+			"userAgent":     "test-agent",
+			"remoteIp":      "192.0.2.1:1234",
+			"protocol":      "HTTP/1.1",
+		},
+	}
+	withLoggingFields := func(m map[string]interface{}) map[string]interface{} {
+		for k, v := range loggingFields {
+			m[k] = v
+		}
+		return m
+	}
+
+	tcs := []struct {
+		name string
+		log  func(l *slog.Logger)
+		opts *slog.HandlerOptions
+		want []map[string]interface{}
+	}{
+		{
+			name: "levels",
+			log: func(l *slog.Logger) {
+				l.InfoContext(r.Context(), "info")
+				l.WarnContext(r.Context(), "warn")
+				l.ErrorContext(r.Context(), "error")
+				l.Log(r.Context(), slog.LevelError+4, "critical")
+				l.DebugContext(r.Context(), "debug is disabled by default")
+			},
+			want: []map[string]interface{}{
+				withLoggingFields(map[string]interface{}{"severity": "INFO", "message": "info"}),
+				withLoggingFields(map[string]interface{}{"severity": "WARNING", "message": "warn"}),
+				withLoggingFields(map[string]interface{}{"severity": "ERROR", "message": "error"}),
+				withLoggingFields(map[string]interface{}{"severity": "CRITICAL", "message": "critical"}),
+			},
+		},
+		{
+			name: "debug level enabled",
+			opts: &slog.HandlerOptions{Level: slog.LevelDebug},
+			log: func(l *slog.Logger) {
+				l.Debug("debug")
+			},
+			want: []map[string]interface{}{
+				{"severity": "DEBUG", "message": "debug"},
+			},
+		},
+		{
+			name: "attributes and groups",
+			log: func(l *slog.Logger) {
+				l = l.With("a", 1).WithGroup("g").With("b", "two")
+				l.InfoContext(r.Context(), "hello", "err", errors.New("boom"), slog.Group("h", "c", true))
+			},
+			want: []map[string]interface{}{
+				withLoggingFields(map[string]interface{}{
+					"severity": "INFO",
+					"message":  "hello",
+					"a":        float64(1),
+					"g": map[string]interface{}{
+						"b":   "two",
+						"err": "boom",
+						"h":   map[string]interface{}{"c": true},
+					},
+				}),
+			},
+		},
+		{
+			name: "no context",
+			log: func(l *slog.Logger) {
+				l.Info("no context")
+			},
+			want: []map[string]interface{}{
+				{"severity": "INFO", "message": "no context"},
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			output := bytes.NewBuffer(nil)
+			tc.log(slog.New(NewLogHandler(output, tc.opts)))
+
+			var got []map[string]interface{}
+			for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+				var entry map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &entry); err != nil {
+					t.Fatalf("invalid JSON log line %q: %v", line, err)
+				}
+				if _, ok := entry["time"]; !ok {
+					t.Errorf("log line %q is missing time", line)
+				}
+				got = append(got, entry)
+			}
+
+			if diff := cmp.Diff(tc.want, got, cmpopts.IgnoreMapEntries(func(k string, _ interface{}) bool { return k == "time" })); diff != "" {
+				t.Errorf("log output mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoggerSourceLocationAndContext(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", bytes.NewReader(nil))
+	r.Header.Set("Function-Execution-Id", "exec")
+	r = addLoggingIDsToRequest(r)
+
+	output := bytes.NewBuffer(nil)
+	h := NewLogHandler(output, &slog.HandlerOptions{AddSource: true})
+	h.ctx = r.Context()
+	slog.New(h).Info("hello")
+
+	var entry struct {
+		SourceLocation map[string]string `json:"logging.googleapis.com/sourceLocation"`
+		Labels         map[string]string `json:"logging.googleapis.com/labels"`
+	}
+	if err := json.Unmarshal(output.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON log line %q: %v", output.String(), err)
+	}
+	if !strings.HasSuffix(entry.SourceLocation["file"], "slog_test.go") || entry.SourceLocation["line"] == "" ||
+		!strings.HasSuffix(entry.SourceLocation["function"], "TestLoggerSourceLocationAndContext") {
+		t.Errorf("unexpected source location %v", entry.SourceLocation)
+	}
+	if got := entry.Labels["execution_id"]; got != "exec" {
+		t.Errorf("expected execution id %q but got %q", "exec", got)
+	}
+
+	if Logger(context.Background()) == nil {
+		t.Errorf("Logger() returned nil")
+	}
+}
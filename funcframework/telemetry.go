@@ -0,0 +1,237 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
+
+var (
+	attrFunctionName   = attribute.Key("faas.name")
+	attrSignatureType  = attribute.Key("faas.signature_type")
+	attrEventType      = attribute.Key("faas.event.type")
+	attrEventID        = attribute.Key("faas.event.id")
+	attrErrorType      = attribute.Key("error.type")
+	attrHTTPMethod     = attribute.Key("http.request.method")
+	attrHTTPStatusCode = attribute.Key("http.response.status_code")
+	attrURLPath        = attribute.Key("url.path")
+	attrFunctionStatus = attribute.Key("faas.status")
+
+	// telemetryInstance is set by EnableOpenTelemetry. If nil, functions are
+	// served without instrumentation.
+	telemetryInstance *telemetry
+)
+
+// TelemetryOption configures the OpenTelemetry instrumentation enabled by
+// EnableOpenTelemetry.
+type TelemetryOption func(*telemetryConfig)
+
+type telemetryConfig struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagator     propagation.TextMapPropagator
+}
+
+// WithTracerProvider sets the TracerProvider used to create invocation spans.
+// Defaults to the global TracerProvider.
+func WithTracerProvider(tp trace.TracerProvider) TelemetryOption {
+	return func(c *telemetryConfig) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider sets the MeterProvider used to record invocation metrics.
+// Defaults to the global MeterProvider.
+func WithMeterProvider(mp metric.MeterProvider) TelemetryOption {
+	return func(c *telemetryConfig) {
+		c.meterProvider = mp
+	}
+}
+
+// WithPropagator sets the propagator used to extract the incoming trace
+// context from request headers. Defaults to the global TextMapPropagator.
+func WithPropagator(p propagation.TextMapPropagator) TelemetryOption {
+	return func(c *telemetryConfig) {
+		c.propagator = p
+	}
+}
+
+// EnableOpenTelemetry instruments every function served by the framework with
+// OpenTelemetry. Each invocation runs in a server span, a child of the
+// incoming trace context if any, carrying the function name, signature type
+// and, for event functions, the event type and ID. Panics and errors returned
+// by the function are recorded on the span. The faas.invocations and
+// faas.invoke_duration metrics are recorded for every invocation, and
+// faas.errors for the failed ones.
+//
+// EnableOpenTelemetry must be called before Start. The span is available to
+// the function through trace.SpanFromContext.
+func EnableOpenTelemetry(opts ...TelemetryOption) error {
+	cfg := telemetryConfig{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+		propagator:     otel.GetTextMapPropagator(),
+	}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	t, err := newTelemetry(cfg)
+	if err != nil {
+		return err
+	}
+	telemetryInstance = t
+	return nil
+}
+
+type telemetry struct {
+	tracer      trace.Tracer
+	propagator  propagation.TextMapPropagator
+	invocations metric.Int64Counter
+	errors      metric.Int64Counter
+	duration    metric.Float64Histogram
+}
+
+func newTelemetry(cfg telemetryConfig) (*telemetry, error) {
+	meter := cfg.meterProvider.Meter(instrumentationName)
+	invocations, err := meter.Int64Counter("faas.invocations",
+		metric.WithDescription("Number of invocations, including failed ones."),
+		metric.WithUnit("{invocation}"))
+	if err != nil {
+		return nil, fmt.Errorf("creating faas.invocations counter: %v", err)
+	}
+	errors, err := meter.Int64Counter("faas.errors",
+		metric.WithDescription("Number of invocation errors."),
+		metric.WithUnit("{error}"))
+	if err != nil {
+		return nil, fmt.Errorf("creating faas.errors counter: %v", err)
+	}
+	duration, err := meter.Float64Histogram("faas.invoke_duration",
+		metric.WithDescription("Measures the duration of the function's logic execution."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, fmt.Errorf("creating faas.invoke_duration histogram: %v", err)
+	}
+
+	return &telemetry{
+		tracer:      cfg.tracerProvider.Tracer(instrumentationName),
+		propagator:  cfg.propagator,
+		invocations: invocations,
+		errors:      errors,
+		duration:    duration,
+	}, nil
+}
+
+// instrument wraps h, the handler serving the function called name, so that
+// every invocation is traced and measured.
+func (t *telemetry) instrument(name, signatureType string, h http.Handler) http.Handler {
+	fnAttrs := []attribute.KeyValue{
+		attrFunctionName.String(name),
+		attrSignatureType.String(signatureType),
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := t.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := t.tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(fnAttrs...),
+			trace.WithAttributes(
+				attrHTTPMethod.String(r.Method),
+				attrURLPath.String(r.URL.Path),
+			))
+		defer span.End()
+
+		sw := &statusRecorder{ResponseWriter: w}
+		h.ServeHTTP(sw, r.WithContext(ctx))
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(attrHTTPStatusCode.Int(status))
+
+		metricAttrs := metric.WithAttributes(fnAttrs...)
+		t.duration.Record(ctx, time.Since(start).Seconds(), metricAttrs)
+		// Like faas.invocations in the OpenTelemetry semantic conventions,
+		// invocations counts failed invocations too, which are also
+		// counted as errors.
+		t.invocations.Add(ctx, 1, metricAttrs)
+		if status < http.StatusInternalServerError {
+			return
+		}
+
+		errType := sw.Header().Get(functionStatusHeader)
+		if errType == "" {
+			errType = strconv.Itoa(status)
+		}
+		span.SetAttributes(attrFunctionStatus.String(errType))
+		span.SetStatus(codes.Error, http.StatusText(status))
+		t.errors.Add(ctx, 1, metricAttrs, metric.WithAttributes(attrErrorType.String(errType)))
+	})
+}
+
+// setEventSpanAttributes records the type and ID of the event being handled
+// on the invocation span in ctx, if any.
+func setEventSpanAttributes(ctx context.Context, eventType, eventID string) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(
+		attrEventType.String(eventType),
+		attrEventID.String(eventID),
+	)
+}
+
+// recordFunctionError records err, returned by the user function, on the
+// invocation span in ctx, if any. The span status is set by instrument based
+// on the response status.
+func recordFunctionError(ctx context.Context, err interface{}) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	e, ok := err.(error)
+	if !ok {
+		e = fmt.Errorf("%v", err)
+	}
+	span.RecordError(e)
+}
+
+// recordFunctionPanic records the value recovered from a panic in the user
+// function on the invocation span in ctx, if any.
+func recordFunctionPanic(ctx context.Context, r interface{}) {
+	if ctx == nil {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	span.RecordError(fmt.Errorf("panic: %v", r), trace.WithStackTrace(true))
+}
@@ -0,0 +1,247 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOpenTelemetry(t *testing.T) {
+	tcs := []struct {
+		name          string
+		register      func()
+		headers       map[string]string
+		body          string
+		wantStatus    int
+		wantAttrs     map[attribute.Key]string
+		wantError     bool
+		wantEvent     string
+		wantParentTID string
+	}{
+		{
+			name: "http",
+			register: func() {
+				functions.HTTP("fn", func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, "Hello World!")
+				})
+			},
+			headers: map[string]string{
+				"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			},
+			wantStatus: http.StatusOK,
+			wantAttrs: map[attribute.Key]string{
+				attrFunctionName:  "fn",
+				attrSignatureType: "http",
+			},
+			wantParentTID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name: "http panic",
+			register: func() {
+				functions.HTTP("fn", func(w http.ResponseWriter, r *http.Request) {
+					panic("intentional panic for test")
+				})
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantAttrs: map[attribute.Key]string{
+				attrFunctionName:   "fn",
+				attrSignatureType:  "http",
+				attrFunctionStatus: "crash",
+			},
+			wantError: true,
+			wantEvent: "panic: intentional panic for test",
+		},
+		{
+			name: "background event error",
+			register: func() {
+				if err := RegisterEventFunctionContext(context.Background(), "/", func(ctx context.Context, data map[string]interface{}) error {
+					return fmt.Errorf("error for test")
+				}); err != nil {
+					t.Fatalf("RegisterEventFunctionContext(): %v", err)
+				}
+			},
+			body: `{
+				"context": {
+					"eventId": "1234567",
+					"timestamp": "2019-11-04T23:01:10.112Z",
+					"eventType": "google.pubsub.topic.publish",
+					"resource": {
+						"service": "pubsub.googleapis.com",
+						"name": "mytopic",
+						"type": "type.googleapis.com/google.pubsub.v1.PubsubMessage"
+					}
+				},
+				"data": {"data": "dGVzdA=="}
+			}`,
+			wantStatus: http.StatusInternalServerError,
+			wantAttrs: map[attribute.Key]string{
+				attrFunctionName:   "/",
+				attrSignatureType:  "event",
+				attrEventType:      "google.pubsub.topic.publish",
+				attrEventID:        "1234567",
+				attrFunctionStatus: "error",
+			},
+			wantError: true,
+			wantEvent: "error for test",
+		},
+		{
+			name: "cloudevent",
+			register: func() {
+				functions.CloudEvent("fn", func(ctx context.Context, e cloudevents.Event) error {
+					return nil
+				})
+			},
+			headers: map[string]string{
+				"ce-specversion": "1.0",
+				"ce-type":        "com.example.test",
+				"ce-source":      "//example.com/source",
+				"ce-id":          "A234-1234-1234",
+				"Content-Type":   "application/json",
+			},
+			body:       `{}`,
+			wantStatus: http.StatusOK,
+			wantAttrs: map[attribute.Key]string{
+				attrFunctionName:  "fn",
+				attrSignatureType: "cloudevent",
+				attrEventType:     "com.example.test",
+				attrEventID:       "A234-1234-1234",
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer cleanup()
+			defer func() { telemetryInstance = nil }()
+
+			spans := tracetest.NewSpanRecorder()
+			reader := sdkmetric.NewManualReader()
+			if err := EnableOpenTelemetry(
+				WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
+				WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+				WithPropagator(propagation.TraceContext{}),
+			); err != nil {
+				t.Fatalf("EnableOpenTelemetry(): %v", err)
+			}
+
+			tc.register()
+			server, err := initServer()
+			if err != nil {
+				t.Fatalf("initServer(): %v", err)
+			}
+			srv := httptest.NewServer(server)
+			defer srv.Close()
+
+			path := "/"
+			if tc.wantAttrs[attrFunctionName] != "/" {
+				path += tc.wantAttrs[attrFunctionName]
+			}
+			req, err := http.NewRequest("POST", srv.URL+path, bytes.NewBufferString(tc.body))
+			if err != nil {
+				t.Fatalf("error creating HTTP request for test: %v", err)
+			}
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("client.Do(%s): %v", tc.name, err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("response status = %v, want %v", resp.StatusCode, tc.wantStatus)
+			}
+
+			ended := spans.Ended()
+			if len(ended) != 1 {
+				t.Fatalf("got %d spans, want 1", len(ended))
+			}
+			span := ended[0]
+			gotAttrs := map[attribute.Key]string{}
+			for _, kv := range span.Attributes() {
+				gotAttrs[kv.Key] = kv.Value.Emit()
+			}
+			for k, want := range tc.wantAttrs {
+				if got := gotAttrs[k]; got != want {
+					t.Errorf("span attribute %s = %q, want %q", k, got, want)
+				}
+			}
+			if got := span.Status().Code == codes.Error; got != tc.wantError {
+				t.Errorf("span error status = %v, want %v", got, tc.wantError)
+			}
+			if tc.wantEvent != "" {
+				found := false
+				for _, e := range span.Events() {
+					for _, kv := range e.Attributes {
+						if kv.Key == "exception.message" && kv.Value.AsString() == tc.wantEvent {
+							found = true
+						}
+					}
+				}
+				if !found {
+					t.Errorf("span events %v do not record exception %q", span.Events(), tc.wantEvent)
+				}
+			}
+			if tc.wantParentTID != "" {
+				if got := span.Parent().TraceID().String(); got != tc.wantParentTID {
+					t.Errorf("span parent trace ID = %q, want %q", got, tc.wantParentTID)
+				}
+			}
+
+			var rm metricdata.ResourceMetrics
+			if err := reader.Collect(context.Background(), &rm); err != nil {
+				t.Fatalf("reader.Collect(): %v", err)
+			}
+			counts := map[string]int64{}
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					switch data := m.Data.(type) {
+					case metricdata.Sum[int64]:
+						for _, dp := range data.DataPoints {
+							counts[m.Name] += dp.Value
+						}
+					case metricdata.Histogram[float64]:
+						for _, dp := range data.DataPoints {
+							counts[m.Name] += int64(dp.Count)
+						}
+					}
+				}
+			}
+			// Failed invocations are counted as invocations and as errors.
+			wantErrors := int64(0)
+			if tc.wantError {
+				wantErrors = 1
+			}
+			if counts["faas.invocations"] != 1 || counts["faas.errors"] != wantErrors || counts["faas.invoke_duration"] != 1 {
+				t.Errorf("got metrics %v, want 1 invocation, %d errors and 1 duration", counts, wantErrors)
+			}
+		})
+	}
+}
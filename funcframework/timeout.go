@@ -0,0 +1,227 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/internal/registry"
+)
+
+const timeoutMessage = "Function execution timed out. Please see logs for more details."
+
+// withTimeout wraps h, the handler serving fn, so that invocations are
+// cancelled after fn.Timeout and, if fn.RespondOnTimeout is set, are answered
+// with 504 Gateway Timeout once their deadline passes.
+func withTimeout(fn *registry.RegisteredFunction, h http.Handler) http.Handler {
+	if fn.Timeout <= 0 && !fn.RespondOnTimeout {
+		return h
+	}
+	name := functionName(fn)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fn.Timeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), fn.Timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+		if !fn.RespondOnTimeout {
+			h.ServeHTTP(w, r)
+			return
+		}
+		r, cancel := setContextTimeoutIfRequested(r)
+		if cancel != nil {
+			defer cancel()
+		}
+		if _, ok := r.Context().Deadline(); !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		serveWithTimeoutResponse(name, w, r, h)
+	})
+}
+
+// serveWithTimeoutResponse runs h in its own goroutine and responds with 504
+// Gateway Timeout if the deadline of r passes before h returns. h keeps
+// running after the deadline, but its writes to the response are discarded.
+func serveWithTimeoutResponse(name string, w http.ResponseWriter, r *http.Request, h http.Handler) {
+	// The execution ID is set up before h runs, so that the timeout response
+	// and the logged stack trace carry the same ID as the function's logs.
+	r = addLoggingIDsToRequest(r)
+	if executionID := ExecutionIDFromContext(r.Context()); executionID != "" {
+		w.Header().Set(executionIDHeader, executionID)
+	}
+	tw := newTimeoutWriter(w)
+	done := make(chan struct{})
+	panicked := make(chan interface{}, 1)
+	goroutine := make(chan uint64, 1)
+	// The function may keep running after the timeout response: it holds on
+	// to its concurrency slot until it actually returns.
+	releaseSlot := handOffConcurrencySlot(r.Context())
+	go func() {
+		defer releaseSlot()
+		defer func() {
+			if p := recover(); p != nil {
+				panicked <- p
+			}
+		}()
+		goroutine <- currentGoroutineID()
+		h.ServeHTTP(tw, r)
+		close(done)
+	}()
+	id := <-goroutine
+
+	ctx := r.Context()
+	select {
+	case p := <-panicked:
+		panic(p)
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+	if ctx.Err() != context.DeadlineExceeded {
+		// The client went away: there is nobody to respond to, so wait for
+		// the function to return as when no timeout response is requested.
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+		}
+		return
+	}
+
+	logFrameworkError(ctx, fmt.Sprintf("Function %q exceeded its deadline and is still running. Stack trace:\n%s", name, goroutineStack(id)))
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.timedOut = true
+	if !tw.wroteHeader {
+		writeErrorResponse(w, http.StatusGatewayTimeout, errorStatus, timeoutMessage)
+	}
+}
+
+// timeoutWriter passes writes through to the wrapped http.ResponseWriter until
+// the invocation times out, after which writes fail with
+// http.ErrHandlerTimeout. Headers are set on a copy owned by the function's
+// goroutine and only copied to the wrapped http.ResponseWriter when the
+// response is started, so that the framework can write its own response
+// concurrently once the deadline passes.
+type timeoutWriter struct {
+	w           http.ResponseWriter
+	h           http.Header
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func newTimeoutWriter(w http.ResponseWriter) *timeoutWriter {
+	return &timeoutWriter{
+		w: w,
+		h: w.Header().Clone(),
+	}
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	return tw.w.Write(b)
+}
+
+func (tw *timeoutWriter) WriteHeader(statusCode int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.writeHeaderLocked(statusCode)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(statusCode int) {
+	dst := tw.w.Header()
+	for k := range dst {
+		delete(dst, k)
+	}
+	for k, v := range tw.h {
+		dst[k] = v
+	}
+	tw.wroteHeader = true
+	tw.w.WriteHeader(statusCode)
+}
+
+// Flush implements http.Flusher so that streaming HTTP functions keep working
+// when their response writer is wrapped.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// currentGoroutineID returns the ID of the calling goroutine, as printed in
+// stack traces.
+func currentGoroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	// The stack starts with "goroutine 123 [running]:".
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i >= 0 {
+		buf = buf[:i]
+	}
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
+
+// goroutineStack returns the stack trace of the goroutine with the given ID,
+// or an empty string if it has exited.
+func goroutineStack(id uint64) string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	prefix := fmt.Sprintf("goroutine %d [", id)
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		if strings.HasPrefix(stack, prefix) {
+			return stack
+		}
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package funcframework
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+)
+
+func TestFunctionTimeout(t *testing.T) {
+	defer cleanup()
+
+	var gotDeadline time.Time
+	functions.HTTP("deadline", func(w http.ResponseWriter, r *http.Request) {
+		gotDeadline, _ = r.Context().Deadline()
+	}, functions.WithTimeout(time.Minute))
+
+	server, err := initServer()
+	if err != nil {
+		t.Fatalf("initServer(): %v", err)
+	}
+	start := time.Now()
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/deadline", nil))
+
+	if gotDeadline.Before(start) || gotDeadline.After(start.Add(time.Minute+time.Second)) {
+		t.Errorf("request context deadline = %v, want about a minute after %v", gotDeadline, start)
+	}
+}
+
+func TestFunctionTimeoutResponse(t *testing.T) {
+	tcs := []struct {
+		name       string
+		fn         func(release chan struct{}) func(http.ResponseWriter, *http.Request)
+		wantStatus int
+		wantBody   string
+		wantStderr string
+	}{
+		{
+			name: "returns in time",
+			fn: func(release chan struct{}) func(http.ResponseWriter, *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, "Hello World!")
+				}
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "Hello World!",
+		},
+		{
+			name: "hangs",
+			fn: func(release chan struct{}) func(http.ResponseWriter, *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					<-release
+					fmt.Fprint(w, "too late")
+				}
+			},
+			wantStatus: http.StatusGatewayTimeout,
+			wantBody:   timeoutMessage + "\n",
+			wantStderr: "timeout_test.go",
+		},
+		{
+			name: "panics",
+			fn: func(release chan struct{}) func(http.ResponseWriter, *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					panic("intentional panic for test")
+				}
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   fmt.Sprintf(panicMessageTmpl, "user function execution") + "\n",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer cleanup()
+			release := make(chan struct{})
+			defer close(release)
+
+			functions.HTTP("fn", tc.fn(release), functions.WithTimeout(100*time.Millisecond), functions.WithTimeoutResponse())
+			server, err := initServer()
+			if err != nil {
+				t.Fatalf("initServer(): %v", err)
+			}
+
+			origStderr := os.Stderr
+			r, w, _ := os.Pipe()
+			os.Stderr = w
+			defer func() { os.Stderr = origStderr }()
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/fn", nil)
+			req.Header.Set(executionIDHeader, "exec")
+			server.ServeHTTP(rec, req)
+
+			w.Close()
+			stderr, _ := io.ReadAll(r)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("response status = %v, want %v", rec.Code, tc.wantStatus)
+			}
+			if got := rec.Body.String(); got != tc.wantBody {
+				t.Errorf("response body = %q, want %q", got, tc.wantBody)
+			}
+			// The execution ID is needed to match the response with the logs,
+			// including the stack trace logged on timeout.
+			if got := rec.Header().Get(executionIDHeader); got != "exec" {
+				t.Errorf("%s header = %q, want %q", executionIDHeader, got, "exec")
+			}
+			if tc.wantStatus == http.StatusGatewayTimeout {
+				if got := rec.Header().Get(functionStatusHeader); got != errorStatus {
+					t.Errorf("%s header = %q, want %q", functionStatusHeader, got, errorStatus)
+				}
+			}
+			if !strings.Contains(string(stderr), tc.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr, tc.wantStderr)
+			}
+		})
+	}
+}
@@ -6,23 +6,71 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/GoogleCloudPlatform/functions-framework-go/internal/registry"
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 )
 
+// Option configures how a function is served.
+type Option = registry.Option
+
+// WithTimeout sets the maximum duration of an invocation of the function. The
+// context of the invocation is cancelled once d has elapsed. If
+// CLOUD_RUN_TIMEOUT_SECONDS is also set, the shorter of the two applies.
+func WithTimeout(d time.Duration) Option {
+	return registry.WithTimeout(d)
+}
+
+// WithTimeoutResponse makes the framework respond to an invocation of the
+// function that exceeds its deadline with 504 Gateway Timeout and
+// `X-Google-Status: error`, rather than waiting for the function to return.
+// The stack of the function is logged to help diagnose why it did not return
+// in time.
+func WithTimeoutResponse() Option {
+	return registry.WithTimeoutResponse()
+}
+
+// WithMaxConcurrency limits the number of concurrent invocations of the
+// function to n. Invocations beyond the limit are rejected with 429 Too Many
+// Requests and a Retry-After header, unless a queue is configured with
+// WithConcurrencyQueue.
+func WithMaxConcurrency(n int) Option {
+	return registry.WithMaxConcurrency(n)
+}
+
+// WithConcurrencyQueue lets up to length invocations beyond the limit set by
+// WithMaxConcurrency wait up to maxWait for a concurrency slot. Invocations
+// arriving when the queue is full are rejected with 429 Too Many Requests and
+// invocations that do not get a slot in time with 503 Service Unavailable,
+// both with a Retry-After header. maxWait must be positive.
+func WithConcurrencyQueue(length int, maxWait time.Duration) Option {
+	return registry.WithConcurrencyQueue(length, maxWait)
+}
+
 // HTTP registers an HTTP function that becomes the function handler served
 // at "/" when environment variable `FUNCTION_TARGET=name`
-func HTTP(name string, fn func(http.ResponseWriter, *http.Request)) {
-	if err := registry.Default().RegisterHTTP(fn, registry.WithName(name)); err != nil {
+func HTTP(name string, fn func(http.ResponseWriter, *http.Request), options ...Option) {
+	if err := registry.Default().RegisterHTTP(fn, append(options, registry.WithName(name))...); err != nil {
 		log.Fatalf("failure to register function: %s", err)
 	}
 }
 
 // CloudEvent registers a CloudEvent function that becomes the function handler
 // served at "/" when environment variable `FUNCTION_TARGET=name`
-func CloudEvent(name string, fn func(context.Context, cloudevents.Event) error) {
-	if err := registry.Default().RegisterCloudEvent(fn, registry.WithName(name)); err != nil {
+func CloudEvent(name string, fn func(context.Context, cloudevents.Event) error, options ...Option) {
+	if err := registry.Default().RegisterCloudEvent(fn, append(options, registry.WithName(name))...); err != nil {
+		log.Fatalf("failure to register function: %s", err)
+	}
+}
+
+// CloudEventResponse registers a CloudEvent function that may reply with a
+// response event. The response event is written back to the caller using the
+// same content mode (binary or structured) as the incoming event. A nil event
+// results in an empty response. The function becomes the function handler
+// served at "/" when environment variable `FUNCTION_TARGET=name`
+func CloudEventResponse(name string, fn func(context.Context, cloudevents.Event) (*cloudevents.Event, error), options ...Option) {
+	if err := registry.Default().RegisterCloudEventResponse(fn, append(options, registry.WithName(name))...); err != nil {
 		log.Fatalf("failure to register function: %s", err)
 	}
 }
@@ -30,9 +78,10 @@ func CloudEvent(name string, fn func(context.Context, cloudevents.Event) error)
 // Typed registers a Typed function that becomes the function handler
 // served at "/" when environment variable `FUNCTION_TARGET=name`
 // This function takes a strong type T as an input and can return a strong type T,
-// built in types, nil and/or error as an output
-func Typed(name string, fn interface{}) {
-	if err := registry.Default().RegisterTyped(fn, registry.WithName(name)); err != nil {
+// built in types, nil and/or error as an output. It may also take a
+// context.Context before T to access the invocation context.
+func Typed(name string, fn interface{}, options ...Option) {
+	if err := registry.Default().RegisterTyped(fn, append(options, registry.WithName(name))...); err != nil {
 		log.Fatalf("failure to register function: %s", err)
 	}
 }
@@ -0,0 +1,436 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
+	"github.com/GoogleCloudPlatform/functions-framework-go/internal/registry"
+	"github.com/GoogleCloudPlatform/functions-framework-go/testdata/conformance/nondeclarative"
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+
+	// Registers the functions served by the declarative builder.
+	_ "github.com/GoogleCloudPlatform/functions-framework-go/testdata/conformance/function"
+)
+
+// outputFile is the file the conformance functions write their input to, in
+// the working directory.
+const outputFile = "function_output.json"
+
+//go:embed testdata/events
+var events embed.FS
+
+// declarative holds the functions registered by the function package when it
+// is initialized.
+var declarative = registry.Default().GetAllFunctions()
+
+// configEnv are the environment variables configuring the server, which are
+// cleared before starting each server.
+var configEnv = []string{"FUNCTION_TARGET", "PORT", "FUNCTION_SIGNATURE_TYPE", "FUNCTION_SOURCE", "FUNCTION_DEBUG", "K_SERVICE", "K_REVISION", "CLOUD_RUN_TIMEOUT_SECONDS"}
+
+func TestMain(m *testing.M) {
+	// The functions write their output to the working directory.
+	dir, err := os.MkdirTemp("", "conformance-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		log.Fatal(err)
+	}
+	code := m.Run()
+	os.Chdir(wd)
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// start registers the functions like the main package of builder in
+// testdata/conformance/cmd, with env set, and serves them in-process as it
+// would with args. It returns the URL of the server, which is stopped at the
+// end of the test.
+func start(t *testing.T, builder string, env map[string]string, args ...string) string {
+	t.Helper()
+	for _, k := range configEnv {
+		t.Setenv(k, env[k])
+	}
+
+	registry.Default().Reset()
+	ctx := context.Background()
+	var err error
+	var serve func(l net.Listener) error
+	switch builder {
+	case "http":
+		err = funcframework.RegisterHTTPFunctionContext(ctx, "/", nondeclarative.HTTP)
+		serve = func(l net.Listener) error { return funcframework.Start("", funcframework.WithListener(l)) }
+	case "legacyevent":
+		err = funcframework.RegisterEventFunctionContext(ctx, "/", nondeclarative.Event)
+		serve = func(l net.Listener) error { return funcframework.Start("", funcframework.WithListener(l)) }
+	case "cloudevent":
+		err = funcframework.RegisterCloudEventFunctionContext(ctx, "/", nondeclarative.CloudEvent)
+		serve = func(l net.Listener) error { return funcframework.Start("", funcframework.WithListener(l)) }
+	case "declarative":
+		err = registerDeclarative()
+		serve = func(l net.Listener) error {
+			cfg, err := funcframework.LoadConfig(args)
+			if err != nil {
+				return err
+			}
+			return funcframework.StartWithConfig(cfg, funcframework.WithListener(l))
+		}
+	default:
+		t.Fatalf("unknown builder %q", builder)
+	}
+	if err != nil {
+		t.Fatalf("registering the %s functions: %v", builder, err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- serve(l)
+	}()
+	t.Cleanup(func() {
+		l.Close()
+		<-errc
+		http.DefaultClient.CloseIdleConnections()
+		registry.Default().Reset()
+	})
+	return "http://" + l.Addr().String()
+}
+
+// registerDeclarative registers the functions of the function package again
+// after the registry was reset.
+func registerDeclarative() error {
+	r := registry.Default()
+	for _, fn := range declarative {
+		opts := []registry.Option{registry.WithName(fn.Name), registry.WithPath(fn.Path)}
+		var err error
+		switch {
+		case fn.HTTPFn != nil:
+			err = r.RegisterHTTP(fn.HTTPFn, opts...)
+		case fn.CloudEventFn != nil:
+			err = r.RegisterCloudEvent(fn.CloudEventFn, opts...)
+		case fn.TypedFn != nil:
+			err = r.RegisterTyped(fn.TypedFn, opts...)
+		default:
+			err = fmt.Errorf("unexpected kind of function %q", fn.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send sends req and returns the response body, failing the test unless the
+// function succeeds.
+func send(t *testing.T, req *http.Request) []byte {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("sending request: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("response status = %s, want 200 OK: %s", resp.Status, body)
+	}
+	return body
+}
+
+// newRequest returns a POST request to url with body, of contentType unless it
+// is empty.
+func newRequest(t *testing.T, url, contentType string, body []byte) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req
+}
+
+// readOutput returns the output written by the function and removes it.
+func readOutput(t *testing.T) []byte {
+	t.Helper()
+	b, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("reading function output: %v", err)
+	}
+	os.Remove(outputFile)
+	return b
+}
+
+// Event types, as in the names of the files in testdata/events.
+const (
+	legacyEvent = "legacy"
+	cloudEvent  = "cloudevent"
+)
+
+// eventInput is an event from testdata/events.
+type eventInput struct {
+	// name is the name of the event, shared by its input and output files.
+	name string
+	// typ is legacyEvent or cloudEvent.
+	typ  string
+	body []byte
+}
+
+// eventInputs returns the events in the files of testdata/events named
+// <name>-<type>-input.json.
+func eventInputs(t *testing.T) []eventInput {
+	t.Helper()
+	entries, err := fs.ReadDir(events, "testdata/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inputs []eventInput
+	for _, e := range entries {
+		for _, typ := range []string{legacyEvent, cloudEvent} {
+			name, ok := strings.CutSuffix(e.Name(), "-"+typ+"-input.json")
+			if !ok {
+				continue
+			}
+			body, err := events.ReadFile("testdata/events/" + e.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			inputs = append(inputs, eventInput{name, typ, body})
+		}
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no events in testdata/events")
+	}
+	return inputs
+}
+
+// eventOutput returns the content of the file of testdata/events named
+// <name>-<typ>-output.json, and whether it exists.
+func eventOutput(t *testing.T, name, typ string) ([]byte, bool) {
+	t.Helper()
+	b, err := events.ReadFile("testdata/events/" + name + "-" + typ + "-output.json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, true
+}
+
+// sendEvents sends every event in testdata/events to url, as is, and checks
+// the output of the function, an event of type typ, against the expected
+// output with validate. Events without an expected output of type typ are
+// skipped.
+func sendEvents(t *testing.T, url, typ string, validate func(t *testing.T, got, want []byte)) {
+	t.Helper()
+	for _, in := range eventInputs(t) {
+		t.Run(in.name+"-"+in.typ, func(t *testing.T) {
+			want, ok := eventOutput(t, in.name, typ)
+			if !ok {
+				t.Skipf("no %s output for %s", typ, in.name)
+			}
+			contentType := "application/json"
+			if in.typ == cloudEvent {
+				contentType = cloudevents.ApplicationCloudEventsJSON
+			}
+			send(t, newRequest(t, url, contentType, in.body))
+			validate(t, readOutput(t), want)
+		})
+	}
+}
+
+// canonicalJSON returns the JSON value in b compacted, with sorted object
+// keys, so that equal values are encoded with the same bytes. The timestamp at
+// the object keys in timestampPath, which the output of the functions has
+// formatted by the Go libraries they use rather than by the framework, is
+// formatted with time.RFC3339Nano: its precision may differ from the expected
+// output.
+func canonicalJSON(b []byte, timestampPath ...string) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	obj, _ := v.(map[string]interface{})
+	for i, k := range timestampPath {
+		if obj == nil {
+			break
+		}
+		if i < len(timestampPath)-1 {
+			obj, _ = obj[k].(map[string]interface{})
+			continue
+		}
+		if s, ok := obj[k].(string); ok {
+			ts, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp %q at %s: %v", s, strings.Join(timestampPath, "."), err)
+			}
+			obj[k] = ts.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return json.Marshal(v)
+}
+
+// validateJSON compares got and want byte for byte once both are made
+// canonical by canonicalJSON with timestampPath.
+func validateJSON(t *testing.T, got, want []byte, timestampPath ...string) {
+	t.Helper()
+	g, err := canonicalJSON(got, timestampPath...)
+	if err != nil {
+		t.Fatalf("parsing output %s: %v", got, err)
+	}
+	w, err := canonicalJSON(want, timestampPath...)
+	if err != nil {
+		t.Fatalf("parsing expected output: %v", err)
+	}
+	if !bytes.Equal(g, w) {
+		t.Errorf("output mismatch:\ngot:  %s\nwant: %s", g, w)
+	}
+}
+
+// validateLegacyEvent compares the background events got and want. The
+// timestamp of the event is formatted by the metadata package.
+func validateLegacyEvent(t *testing.T, got, want []byte) {
+	t.Helper()
+	validateJSON(t, got, want, "context", "timestamp")
+}
+
+// validateCloudEvent compares the structured mode CloudEvents got and want.
+// The time attribute is formatted by the CloudEvents SDK.
+func validateCloudEvent(t *testing.T, got, want []byte) {
+	t.Helper()
+	validateJSON(t, got, want, "time")
+}
+
+func TestHTTP(t *testing.T) {
+	body := []byte(`{"firstName":"Jane", "lastName": "Doe"}` + "\n")
+	for _, tc := range []struct {
+		builder string
+		args    []string
+	}{
+		{builder: "http"},
+		{builder: "declarative", args: []string{"--target=declarativeHTTP"}},
+	} {
+		t.Run(tc.builder, func(t *testing.T) {
+			url := start(t, tc.builder, nil, tc.args...)
+			send(t, newRequest(t, url, "application/json", body))
+			if got := readOutput(t); !bytes.Equal(got, body) {
+				t.Errorf("output = %q, want the request body %q", got, body)
+			}
+		})
+	}
+}
+
+func TestLegacyEvent(t *testing.T) {
+	url := start(t, "legacyevent", nil)
+	sendEvents(t, url, legacyEvent, validateLegacyEvent)
+}
+
+func TestCloudEvent(t *testing.T) {
+	for _, tc := range []struct {
+		builder string
+		args    []string
+	}{
+		{builder: "cloudevent"},
+		{builder: "declarative", args: []string{"--target=declarativeCloudEvent", "--signature-type=cloudevent"}},
+	} {
+		t.Run(tc.builder, func(t *testing.T) {
+			url := start(t, tc.builder, nil, tc.args...)
+			sendEvents(t, url, cloudEvent, validateCloudEvent)
+		})
+	}
+}
+
+func TestTyped(t *testing.T) {
+	url := start(t, "declarative", nil, "--target=declarativeTyped", "--signature-type=typed")
+	got := send(t, newRequest(t, url, "application/json", []byte(`{"message":"foo"}`)))
+	if want := `{"payload":{"message":"foo"}}`; string(got) != want {
+		t.Errorf("response = %q, want %q", got, want)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	// concurrentHTTP takes one second to respond.
+	url := start(t, "declarative", nil, "--target=concurrentHTTP")
+	const n = 10
+	begin := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(url)
+			if err != nil {
+				t.Errorf("request: %v", err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("response status = %s, want 200 OK", resp.Status)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(begin); elapsed >= 2*time.Second {
+		t.Errorf("%d concurrent requests took %v, want them to be handled concurrently", n, elapsed)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	// timeoutHTTP responds once its request is cancelled.
+	url := start(t, "declarative", map[string]string{"CLOUD_RUN_TIMEOUT_SECONDS": "1"}, "--target=timeoutHTTP")
+	begin := time.Now()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if want := context.DeadlineExceeded.Error(); string(body) != want {
+		t.Errorf("response = %q, want %q", body, want)
+	}
+	if elapsed := time.Since(begin); elapsed < time.Second {
+		t.Errorf("request cancelled after %v, want the 1s timeout", elapsed)
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conformance contains an offline version of the Functions Framework
+// conformance tests, run by testdata/conformance/run_conformance_tests.sh with
+// the conformance client. The tests serve the functions in
+// testdata/conformance in-process, registered as by the main packages in
+// testdata/conformance/cmd, and send them requests over HTTP.
+//
+// The events in testdata/events are the input and expected output events of
+// the conformance tests, from the events/generate/data directory of
+// https://github.com/GoogleCloudPlatform/functions-framework-conformance.
+// They are sent to the functions as they are, and only updated by copying
+// them from there again. The tests compare the output of the functions with
+// the expected output byte for byte once both are compacted with sorted object
+// keys. Only the event timestamp, formatted by the CloudEvents SDK or the
+// metadata package rather than by the framework, may differ in precision.
+package conformance
@@ -0,0 +1,41 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package invoker gives the fftest package and the ffevent command access to
+// the unexported parts of the funcframework package they need to invoke
+// functions the way the framework serves them. The variables are set when the
+// funcframework package is initialized.
+package invoker
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/internal/registry"
+)
+
+var (
+	// WrapFunction returns the handler serving fn.
+	WrapFunction func(fn *registry.RegisteredFunction) (http.Handler, error)
+
+	// ContextWithLogOutput returns a copy of ctx in which the log entries of
+	// the invocation go to w instead of stderr.
+	ContextWithLogOutput func(ctx context.Context, w io.Writer) context.Context
+
+	// BackgroundEventToCloudEvent returns the structured mode CloudEvent
+	// equivalent to the JSON encoded background event, as received by
+	// CloudEvent functions that are sent the background event.
+	BackgroundEventToCloudEvent func(event []byte) ([]byte, error)
+)
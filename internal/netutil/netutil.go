@@ -0,0 +1,68 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package netutil opens the listeners of the servers started by the
+// funcframework package and by the ff command.
+package netutil
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// UnixSocketPrefix prefixes the ports that are Unix domain socket paths, as in
+// "unix:///tmp/fn.sock".
+const UnixSocketPrefix = "unix://"
+
+// Listen opens the listener for port on hostname. If port starts with
+// UnixSocketPrefix, it listens on the Unix domain socket at the rest of port
+// instead, and hostname is ignored.
+func Listen(hostname, port string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(port, UnixSocketPrefix)
+	if !ok {
+		return net.Listen("tcp", net.JoinHostPort(hostname, port))
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	return net.Listen("unix", path)
+}
+
+// removeStaleSocket removes the socket at path left behind by a server that
+// is no longer running, as it would prevent listening on the same path. It
+// returns an error if a server still accepts connections on the socket.
+func removeStaleSocket(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		// Let net.Listen report missing directories and non-socket files.
+		return nil
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("socket %q is in use by another server", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("failed to check socket %q: %v", path, err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove stale socket %q: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netutil
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fn.sock")
+	for i := 0; i < 2; i++ {
+		// The second listener replaces the stale socket left behind by the
+		// first one.
+		l, err := Listen("", UnixSocketPrefix+path)
+		if err != nil {
+			t.Fatalf("Listen(): %v", err)
+		}
+		if l, ok := l.(*net.UnixListener); ok {
+			l.SetUnlinkOnClose(false)
+		}
+		srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "Hello World!")
+		})}
+		go srv.Serve(l)
+
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}}
+		resp, err := client.Get("http://unix/")
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "Hello World!" {
+			t.Errorf("response body = %q, want %q", body, "Hello World!")
+		}
+		srv.Close()
+	}
+}
+
+func TestListenUnixSocketInUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fn.sock")
+	l, err := Listen("", UnixSocketPrefix+path)
+	if err != nil {
+		t.Fatalf("Listen(): %v", err)
+	}
+	defer l.Close()
+
+	if l2, err := Listen("", UnixSocketPrefix+path); err == nil {
+		l2.Close()
+		t.Fatalf("Listen() on a socket in use succeeded, want error")
+	}
+	// The socket of the running server is left in place.
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("dialing the socket in use: %v", err)
+	}
+	conn.Close()
+}
+
+func TestListenUnixSocketNotASocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fn.sock")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := Listen("", UnixSocketPrefix+path); err == nil {
+		l.Close()
+		t.Fatalf("Listen() on a regular file succeeded, want error")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("regular file was removed: %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 )
@@ -11,12 +12,18 @@ import (
 // RegisteredFunction represents a function that has been
 // registered with the registry.
 type RegisteredFunction struct {
-	Name         string                                         // The name of the function
-	Path         string                                         // The serving path of the function
-	CloudEventFn func(context.Context, cloudevents.Event) error // Optional: The user's CloudEvent function
-	HTTPFn       func(http.ResponseWriter, *http.Request)       // Optional: The user's HTTP function
-	EventFn      interface{}                                    // Optional: The user's Event function
-	TypedFn      interface{}                                    // Optional: The user's typed function
+	Name                 string                                                               // The name of the function
+	Path                 string                                                               // The serving path of the function
+	CloudEventFn         func(context.Context, cloudevents.Event) error                       // Optional: The user's CloudEvent function
+	CloudEventResponseFn func(context.Context, cloudevents.Event) (*cloudevents.Event, error) // Optional: The user's CloudEvent function that replies with an event
+	HTTPFn               func(http.ResponseWriter, *http.Request)                             // Optional: The user's HTTP function
+	EventFn              interface{}                                                          // Optional: The user's Event function
+	TypedFn              interface{}                                                          // Optional: The user's typed function
+	Timeout              time.Duration                                                        // Optional: The maximum duration of an invocation
+	RespondOnTimeout     bool                                                                 // Optional: Whether to respond with an error when an invocation times out
+	MaxConcurrency       int                                                                  // Optional: The maximum number of concurrent invocations
+	MaxQueueLength       int                                                                  // Optional: The maximum number of invocations waiting for a concurrency slot
+	MaxQueueWait         time.Duration                                                        // Optional: The maximum duration an invocation waits for a concurrency slot
 }
 
 // Option is an option used when registering a function.
@@ -34,6 +41,46 @@ func WithName(name string) Option {
 	}
 }
 
+// WithTimeout sets the maximum duration of an invocation of the function. The
+// context of the invocation is cancelled once d has elapsed. If
+// CLOUD_RUN_TIMEOUT_SECONDS is also set, the shorter of the two applies.
+func WithTimeout(d time.Duration) Option {
+	return func(fn *RegisteredFunction) {
+		fn.Timeout = d
+	}
+}
+
+// WithTimeoutResponse makes the framework respond to an invocation of the
+// function that exceeds its deadline with 504 Gateway Timeout, rather than
+// waiting for the function to return. The stack of the function is logged to
+// help diagnose why it did not return in time.
+func WithTimeoutResponse() Option {
+	return func(fn *RegisteredFunction) {
+		fn.RespondOnTimeout = true
+	}
+}
+
+// WithMaxConcurrency limits the number of concurrent invocations of the
+// function to n. Invocations beyond the limit are rejected with 429 Too Many
+// Requests, unless a queue is configured with WithConcurrencyQueue.
+func WithMaxConcurrency(n int) Option {
+	return func(fn *RegisteredFunction) {
+		fn.MaxConcurrency = n
+	}
+}
+
+// WithConcurrencyQueue lets up to length invocations beyond the limit set by
+// WithMaxConcurrency wait up to maxWait for a concurrency slot. Invocations
+// arriving when the queue is full are rejected with 429 Too Many Requests and
+// invocations that do not get a slot in time with 503 Service Unavailable.
+// maxWait must be positive.
+func WithConcurrencyQueue(length int, maxWait time.Duration) Option {
+	return func(fn *RegisteredFunction) {
+		fn.MaxQueueLength = length
+		fn.MaxQueueWait = maxWait
+	}
+}
+
 // Registry is a registry of functions.
 type Registry struct {
 	functions             map[string]*RegisteredFunction
@@ -68,6 +115,12 @@ func (r *Registry) RegisterCloudEvent(fn func(context.Context, cloudevents.Event
 	return r.register(&RegisteredFunction{CloudEventFn: fn}, options...)
 }
 
+// RegisterCloudEventResponse registers a CloudEvent function that may reply
+// with a response event.
+func (r *Registry) RegisterCloudEventResponse(fn func(context.Context, cloudevents.Event) (*cloudevents.Event, error), options ...Option) error {
+	return r.register(&RegisteredFunction{CloudEventResponseFn: fn}, options...)
+}
+
 // RegisterEvent registers an Event function.
 func (r *Registry) RegisterEvent(fn interface{}, options ...Option) error {
 	return r.register(&RegisteredFunction{EventFn: fn}, options...)
@@ -85,6 +138,9 @@ func (r *Registry) register(function *RegisteredFunction, options ...Option) err
 	if function.Name == "" && function.Path == "" {
 		return fmt.Errorf("either the function path or the function name should be specified")
 	}
+	if function.MaxQueueLength > 0 && function.MaxQueueWait <= 0 {
+		return fmt.Errorf("the maximum concurrency queue wait must be positive, got %v", function.MaxQueueWait)
+	}
 	if function.Name == "" {
 		// The function is not registered declaratively.
 		r.functionsWithoutNames = append(r.functionsWithoutNames, function)
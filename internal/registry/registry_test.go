@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 )
@@ -135,3 +136,38 @@ func TestRegisterMultipleFunctionsError(t *testing.T) {
 		t.Error("Expected error registering function with same name")
 	}
 }
+
+func TestRegisterWithTimeout(t *testing.T) {
+	registry := New()
+	if err := registry.RegisterHTTP(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Hello World!")
+	}, WithName("timeout"), WithTimeout(time.Minute), WithTimeoutResponse()); err != nil {
+		t.Fatalf("Expected no error registering function: %v", err)
+	}
+
+	fn, ok := registry.GetRegisteredFunction("timeout")
+	if !ok {
+		t.Fatalf("Expected function to be registered")
+	}
+	if fn.Timeout != time.Minute {
+		t.Errorf("Expected function timeout to be %v, got %v", time.Minute, fn.Timeout)
+	}
+	if !fn.RespondOnTimeout {
+		t.Errorf("Expected function to respond on timeout")
+	}
+}
+
+func TestRegisterWithConcurrencyQueue(t *testing.T) {
+	for _, maxWait := range []time.Duration{0, -time.Second} {
+		registry := New()
+		err := registry.RegisterHTTP(func(w http.ResponseWriter, r *http.Request) {}, WithName("queued"), WithMaxConcurrency(1), WithConcurrencyQueue(1, maxWait))
+		if err == nil {
+			t.Errorf("Expected an error registering a function with a maximum queue wait of %v", maxWait)
+		}
+	}
+
+	registry := New()
+	if err := registry.RegisterHTTP(func(w http.ResponseWriter, r *http.Request) {}, WithName("queued"), WithMaxConcurrency(1), WithConcurrencyQueue(1, time.Second)); err != nil {
+		t.Errorf("Expected no error registering function: %v", err)
+	}
+}
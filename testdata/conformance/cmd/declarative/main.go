@@ -26,12 +26,13 @@ import (
 // Main function used for testing only:
 // FUNCTION_TARGET=declarativeHTTP go run testdata/conformance/cmd/declarative/main.go
 // FUNCTION_TARGET=declarativeCloudEvent go run testdata/conformance/cmd/declarative/main.go
+// go run testdata/conformance/cmd/declarative/main.go --target=declarativeHTTP
 func main() {
-	port := "8080"
-	if envPort := os.Getenv("PORT"); envPort != "" {
-		port = envPort
+	cfg, err := funcframework.LoadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
 	}
-	if err := funcframework.Start(port); err != nil {
+	if err := funcframework.StartWithConfig(cfg); err != nil {
 		log.Fatalf("Failed to start functions framework: %v", err)
 	}
 }
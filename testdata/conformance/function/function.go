@@ -37,6 +37,7 @@ const (
 func init() {
 	functions.HTTP("declarativeHTTP", HTTP)
 	functions.HTTP("concurrentHTTP", concurrentHTTP)
+	functions.HTTP("timeoutHTTP", timeoutHTTP)
 	functions.Typed("declarativeTyped", Typed)
 	functions.CloudEvent("declarativeCloudEvent", CloudEvent)
 }
@@ -45,6 +46,13 @@ func concurrentHTTP(w http.ResponseWriter, r *http.Request) {
 	time.Sleep(1 * time.Second)
 }
 
+// timeoutHTTP waits for the request to be cancelled, for example when it
+// exceeds the timeout of the function, and responds with the reason.
+func timeoutHTTP(w http.ResponseWriter, r *http.Request) {
+	<-r.Context().Done()
+	fmt.Fprint(w, r.Context().Err())
+}
+
 // HTTP is a simple HTTP function that writes the request body to the response body.
 func HTTP(w http.ResponseWriter, r *http.Request) {
 	l := log.New(funcframework.LogWriter(r.Context()), "", log.Lshortfile)
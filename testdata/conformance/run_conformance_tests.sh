@@ -12,6 +12,9 @@
 # The version of the conformance tests client to use, formatted as "vX.X.X".
 # Defaults to the latest version of the repo, which may be ahead of the
 # latest release.
+#
+# The same scenarios run offline and in-process, without the client, with
+# `go test ./internal/conformance` from the repository root.
 
 # exit when any command fails
 set -e